@@ -1,19 +1,33 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"qbittorrent_exporter/config"
 	"qbittorrent_exporter/feature"
+	"qbittorrent_exporter/forecast"
+	"qbittorrent_exporter/goals"
+	"qbittorrent_exporter/inventory"
 	"qbittorrent_exporter/lib/log"
 	"qbittorrent_exporter/lib/qbittorrent/api"
 	"qbittorrent_exporter/lib/scheduler"
+	"qbittorrent_exporter/lib/tlsconfig"
 	"qbittorrent_exporter/metrics"
+	"qbittorrent_exporter/problems"
+	"qbittorrent_exporter/rolling"
 	"qbittorrent_exporter/state"
+	"qbittorrent_exporter/web"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -21,11 +35,12 @@ import (
 
 const version = "1.0.2"
 
-const (
-	torrentUpdateInterval  = 30 * time.Second
-	transferUpdateInterval = 30 * time.Second
-	versionCheckInterval   = 10 * time.Minute
-)
+// connectionStatus is the last connection status
+// reported by qBittorrent transfer info
+var connectionStatus atomic.Value
+
+// printReference prints the metrics reference instead of running the exporter
+var printReference bool
 
 func init() {
 	flag.Usage = func() {
@@ -40,8 +55,11 @@ func init() {
 		logFormat     string
 		configPath    string
 		metricsPrefix string
+		metricsNaming string
+		webConfigPath string
 		useFeatures   = map[feature.FeatureFlag]bool{
-			feature.TRANSIENT_STATE: false,
+			feature.TRANSIENT_STATE:     false,
+			feature.LEGACY_TOTAL_GAUGES: false,
 		}
 	)
 
@@ -49,6 +67,9 @@ func init() {
 	flag.StringVar(&logFormat, "log-format", "default", "Log format")
 	flag.StringVar(&configPath, "config", "config.yaml", "Path to yaml config.")
 	flag.StringVar(&metricsPrefix, "prefix", "qb_", "Metrics prefix.")
+	flag.StringVar(&metricsNaming, "metrics-naming", metrics.NamingLegacy, "Metrics naming: legacy, v2 or both.")
+	flag.BoolVar(&printReference, "metrics-reference", false, "Print the metrics reference in markdown and exit.")
+	flag.StringVar(&webConfigPath, "web.config.file", "", "Path to web config enabling TLS and basic auth.")
 
 	setFeatures := feature.Use(useFeatures)
 	defer setFeatures()
@@ -56,21 +77,71 @@ func init() {
 
 	config.UpdatePath(configPath)
 	metrics.UpdatePrefix(metricsPrefix)
+	web.UpdateConfigPath(webConfigPath)
 	log.Set(logLevel, logFormat)
+	if err := metrics.UpdateNaming(metricsNaming); err != nil {
+		log.Fatal(err.Error())
+	}
 }
 
 func main() {
+	if printReference {
+		fmt.Print(metrics.Reference())
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Info("Shutting down")
+		scheduler.Get().Shutdown()
+	}()
+
 	cfg := config.Get()
+	if err := config.ValidatePolling(cfg); err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := config.ValidateProblems(cfg); err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := config.ValidateRolling(cfg); err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := config.ValidateMetricsPath(cfg); err != nil {
+		log.Fatal(err.Error())
+	}
 	initializeState(cfg)
-	client := newHTTPClient(cfg)
+	metrics.UpdateTorrentMetrics(cfg.Metrics.Torrent)
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
-	api, err := api.NewQBittorrentAPI(&api.QBittorrentAPIOpts{
-		BaseURL: cfg.QBittorrent.BaseURL,
-		Credentials: &api.QBittorrentCredentials{
+	authMode, err := cfg.QBittorrent.AuthMode()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	var credentials *api.QBittorrentCredentials
+	if authMode == config.AuthPassword {
+		credentials = &api.QBittorrentCredentials{
 			Username: cfg.QBittorrent.Username,
 			Password: cfg.QBittorrent.Password,
+		}
+	} else {
+		log.Info("qBittorrent authentication is disabled, skipping login")
+	}
+
+	api, err := api.NewQBittorrentAPIContext(ctx, &api.QBittorrentAPIOpts{
+		BaseURL:     cfg.QBittorrent.BaseURL,
+		Credentials: credentials,
+		HttpClient:  client,
+		ReverseProxy: &api.ReverseProxyAuth{
+			Username:        cfg.QBittorrent.ReverseProxy.Username,
+			Password:        cfg.QBittorrent.ReverseProxy.Password,
+			Headers:         cfg.QBittorrent.ReverseProxy.Headers,
+			BearerTokenFile: cfg.QBittorrent.ReverseProxy.BearerTokenFile,
 		},
-		HttpClient: client,
 	})
 	if err != nil {
 		log.Fatal(err.Error())
@@ -79,6 +150,9 @@ func main() {
 	runScheduledTasks(api, cfg)
 
 	scheduler.Get().Wait()
+	if err := state.Flush(); err != nil {
+		log.Error(err.Error())
+	}
 }
 
 func initializeState(cfg config.Config) {
@@ -92,66 +166,336 @@ func initializeState(cfg config.Config) {
 	}
 }
 
-func newHTTPClient(cfg config.Config) *http.Client {
+func newHTTPClient(cfg config.Config) (*http.Client, error) {
+	qb := cfg.QBittorrent
+	tlsOpts := &tlsconfig.ClientOpts{
+		InsecureSkipVerify: qb.InsecureSkipVerify,
+		CAFile:             qb.CAFile,
+		CertFile:           qb.CertFile,
+		KeyFile:            qb.KeyFile,
+		ServerName:         qb.ServerName,
+	}
+	baseURL, err := url.Parse(qb.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid qBittorrent baseUrl: %w", err)
+	}
+	tlsOpts.Host = baseURL.Hostname()
+	if qb.MinTLSVersion != "" {
+		v, err := tlsconfig.ParseVersion(qb.MinTLSVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid qBittorrent minTlsVersion: %w", err)
+		}
+		tlsOpts.MinVersion = v
+	}
+	tlsConfig, err := tlsconfig.NewClientConfig(tlsOpts)
+	if err != nil {
+		return nil, fmt.Errorf("qBittorrent TLS config: %w", err)
+	}
+
+	proxy, err := newProxyFunc(qb.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("qBittorrent proxy: %w", err)
+	}
+
+	conn := qb.Connection
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
 	return &http.Client{
 		Transport: &http.Transport{
-			DisableKeepAlives: true,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: cfg.QBittorrent.InsecureSkipVerify,
-			},
+			Proxy:               proxy,
+			DialContext:         dialer.DialContext,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: 10 * time.Second,
+			DisableKeepAlives:   !conn.KeepAlive,
+			MaxIdleConns:        conn.MaxIdleConns,
+			MaxIdleConnsPerHost: conn.MaxIdleConnsPerHost,
+			IdleConnTimeout:     conn.IdleConnTimeout,
+			// custom TLS config disables HTTP/2 unless forced
+			ForceAttemptHTTP2: conn.HTTP2,
+			// transport requests gzip and decompresses
+			// responses transparently when enabled
+			DisableCompression: !conn.Compression,
 		},
-		Timeout: time.Duration(cfg.QBittorrent.Timeout) * time.Second,
+		Timeout: time.Duration(qb.Timeout) * time.Second,
+	}, nil
+}
+
+// newProxyFunc returns proxy configured explicitly,
+// or proxy from environment variables otherwise
+func newProxyFunc(cfg config.ProxyConfig) (func(*http.Request) (*url.URL, error), error) {
+	if cfg.URL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
+	}
+	if cfg.Username != "" {
+		proxyURL.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	log.Info("Using proxy " + proxyURL.Redacted() + " to reach qBittorrent")
+	return http.ProxyURL(proxyURL), nil
+}
+
+func serveMetrics(ctx context.Context, api *api.QBittorrentAPI, cfg config.Config) error {
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Metrics.UrlPath, promhttp.Handler())
+	mux.Handle("/{$}", web.LandingHandler(web.LandingOpts{
+		Version:  version,
+		Config:   cfg,
+		LoggedIn: api.IsLoggedIn,
+		ConnectionStatus: func() string {
+			status, _ := connectionStatus.Load().(string)
+			return status
+		},
+	}))
+	mux.Handle("/debug/tasks", web.TasksHandler())
+	mux.Handle("/healthz", web.HealthHandler(web.HealthOpts{
+		OverdueThreshold: cfg.Health.OverdueThreshold,
+	}))
+	mux.Handle("/readyz", web.ReadyHandler(web.ReadyOpts{
+		Tasks:          pollingTasks(cfg.Polling),
+		StaleIntervals: cfg.Health.StaleIntervals,
+		LoggedIn:       api.IsLoggedIn,
+	}))
+
+	socketMode, err := cfg.Metrics.SocketFileMode()
+	if err != nil {
+		return err
 	}
+	listener, err := web.Listen(cfg.Metrics.Address(), socketMode)
+	if err != nil {
+		return fmt.Errorf("metrics server listen: %w", err)
+	}
+	log.Info("Metrics server is available on " + web.ListenerURL(listener, cfg.Metrics.UrlPath))
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.Metrics.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Metrics.ReadTimeout,
+		WriteTimeout:      cfg.Metrics.WriteTimeout,
+		IdleTimeout:       cfg.Metrics.IdleTimeout,
+	}
+	return web.Serve(ctx, server, listener)
 }
 
 func runScheduledTasks(api *api.QBittorrentAPI, cfg config.Config) {
-	scheduler.Run(func() error {
-		http.Handle(cfg.Metrics.UrlPath, promhttp.Handler())
-		addr := fmt.Sprintf("http://0.0.0.0:%s%s", cfg.Metrics.Port, cfg.Metrics.UrlPath)
-		log.Info("Metrics server is available on port " + addr)
-		if err := http.ListenAndServe(":"+cfg.Metrics.Port, nil); err != nil {
-			log.Error(err.Error())
-		}
-		return nil
+	scheduler.Run("metrics-server", func(ctx context.Context) error {
+		return serveMetrics(ctx, api, cfg)
 	}, nil)
 
 	metricsClient := metrics.Get()
 	st := state.Get()
+	polling := cfg.Polling
 
-	scheduler.Run(func() error {
-		torrents, err := api.TorrentsInfo()
-		if err != nil {
-			return err
-		}
-		metricsClient.UpdateTorrent(torrents)
-		return nil
-	}, &scheduler.PeriodicTaskOpts{
-		Interval: torrentUpdateInterval,
-		IsFast:   true,
-	})
+	var detector *problems.Detector
+	if cfg.Problems.Enabled {
+		detector = problems.NewDetector(cfg.Problems)
+	}
+	var goalsEvaluator *goals.Evaluator
+	if cfg.Goals.Enabled {
+		goalsEvaluator = goals.NewEvaluator(cfg.Goals)
+	}
+	var rollingStats *rolling.Stats
+	if cfg.Rolling.Enabled {
+		rollingStats = rolling.NewStats(cfg.Rolling.Windows)
+	}
+	var forecaster *forecast.Forecaster
+	if cfg.Forecast.Enabled {
+		forecaster = forecast.NewForecaster()
+	}
+	inv := inventory.NewInventory()
 
-	scheduler.Run(func() error {
-		transfer, err := api.TransferInfo()
-		if err != nil {
-			return err
-		}
-		st.UpdateTransferInfo(transfer.DlInfoData, transfer.UpInfoData)
-		metricsClient.UpdateTransfer(transfer, st.TransferInfo)
-		return nil
-	}, &scheduler.PeriodicTaskOpts{
-		Interval: transferUpdateInterval,
-		IsFast:   true,
-	})
+	if polling.Torrents.Enabled {
+		scheduler.Run("torrents", withTaskErrors("torrents", func(ctx context.Context) error {
+			torrents, err := api.TorrentsInfoContext(ctx)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			states := st.UpdateTorrentStates(torrents, now)
+			metricsClient.UpdateTorrent(torrents, states)
+			if detector != nil {
+				metricsClient.UpdateProblems(detector.Evaluate(torrents, states, now))
+			}
+			if goalsEvaluator != nil {
+				metricsClient.UpdateGoals(goalsEvaluator.Evaluate(torrents))
+			}
+			var rates []rolling.Rates
+			if rollingStats != nil {
+				rates = rollingStats.Update(torrents, now)
+				metricsClient.UpdateRolling(rates)
+			}
+			if forecaster != nil {
+				metricsClient.UpdateForecast(forecaster.Forecast(torrents, rates))
+			}
+			metricsClient.UpdateInventory(inv.Summarize(torrents))
+			return nil
+		}), newPeriodicTaskOpts("torrents", polling.Torrents.Interval, cfg))
+	}
 
-	scheduler.Run(func() error {
-		version, err := api.AppVersion()
-		if err != nil {
-			return err
+	if polling.Transfer.Enabled {
+		scheduler.Run("transfer", withTaskErrors("transfer", func(ctx context.Context) error {
+			transfer, err := api.TransferInfoContext(ctx)
+			if err != nil {
+				return err
+			}
+			connectionStatus.Store(transfer.ConnectionStatus)
+			st.UpdateTransferInfo(transfer.DlInfoData, transfer.UpInfoData)
+			metricsClient.UpdateTransfer(transfer, st.TransferInfo)
+			return nil
+		}), newPeriodicTaskOpts("transfer", polling.Transfer.Interval, cfg))
+	}
+
+	if polling.Version.Enabled {
+		scheduler.Run("version", withTaskErrors("version", func(ctx context.Context) error {
+			version, err := api.AppVersionContext(ctx)
+			if err != nil {
+				return err
+			}
+			metricsClient.UpdateVersion(version)
+			if v := api.WebAPIVersion(); !v.IsZero() {
+				metricsClient.UpdateWebAPIVersion(v.String())
+			}
+			return nil
+		}), newPeriodicTaskOpts("version", polling.Version.Interval, cfg))
+	}
+
+	if polling.MainData.Enabled {
+		scheduler.Run("maindata", withTaskErrors("maindata", func(ctx context.Context) error {
+			mainData, err := api.MainDataContext(ctx)
+			if err != nil {
+				return err
+			}
+			if freeSpace := mainData.ServerState.FreeSpaceOnDisk; freeSpace != nil {
+				metricsClient.UpdateFreeSpace(*freeSpace)
+				if forecaster != nil {
+					forecaster.UpdateFreeSpace(*freeSpace)
+				}
+			}
+			return nil
+		}), newPeriodicTaskOpts("maindata", polling.MainData.Interval, cfg))
+	}
+
+	if polling.Categories.Enabled {
+		scheduler.Run("categories", withTaskErrors("categories", func(ctx context.Context) error {
+			categories, err := api.CategoriesContext(ctx)
+			if err != nil {
+				return err
+			}
+			inv.UpdateCategories(categories)
+			metricsClient.UpdateCategories(categories)
+			return nil
+		}), newPeriodicTaskOpts("categories", polling.Categories.Interval, cfg))
+	}
+
+	if polling.Tags.Enabled {
+		scheduler.Run("tags", withTaskErrors("tags", func(ctx context.Context) error {
+			tags, err := api.TagsContext(ctx)
+			if err != nil {
+				return err
+			}
+			inv.UpdateTags(tags)
+			return nil
+		}), newPeriodicTaskOpts("tags", polling.Tags.Interval, cfg))
+	}
+
+	if polling.SpeedLimits.Enabled {
+		scheduler.Run("speedlimits", withTaskErrors("speedlimits", func(ctx context.Context) error {
+			altEnabled, err := api.SpeedLimitsModeContext(ctx)
+			if err != nil {
+				return err
+			}
+			preferences, err := api.PreferencesContext(ctx)
+			if err != nil {
+				return err
+			}
+			metricsClient.UpdateSpeedLimits(altEnabled, preferences)
+			return nil
+		}), newPeriodicTaskOpts("speedlimits", polling.SpeedLimits.Interval, cfg))
+	}
+
+	if polling.State.Enabled {
+		state.RunPeriodicWrite(polling.State.Interval)
+	}
+}
+
+// withTaskErrors counts failed attempts of the task by reason
+// and skips the task once its endpoint turns out unsupported
+func withTaskErrors(task string, fn func(context.Context) error) func(context.Context) error {
+	metricsClient := metrics.Get()
+	var once sync.Once
+	return func(ctx context.Context) error {
+		err := fn(ctx)
+		if errors.Is(err, api.ErrUnsupportedAPIVersion) {
+			once.Do(func() {
+				log.Warn("Skipping task unsupported by qBittorrent: "+err.Error(), "task", task)
+			})
+			return nil
 		}
-		metricsClient.UpdateVersion(version)
-		return nil
-	}, &scheduler.PeriodicTaskOpts{
-		Interval: versionCheckInterval,
-		IsFast:   true,
-	})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			metricsClient.IncTaskError(task, api.Reason(err))
+		}
+		return err
+	}
+}
+
+func newPeriodicTaskOpts(task string, interval time.Duration, cfg config.Config) *scheduler.PeriodicTaskOpts {
+	metricsClient := metrics.Get()
+	polling := cfg.Polling
+	return &scheduler.PeriodicTaskOpts{
+		Interval:    interval,
+		IsFast:      true,
+		Timeout:     time.Duration(cfg.QBittorrent.Timeout) * time.Second,
+		StartJitter: polling.StartJitter,
+		Retry: &scheduler.RetryPolicy{
+			MaxAttempts:    polling.Retry.MaxAttempts,
+			InitialBackoff: polling.Retry.InitialBackoff,
+			MaxBackoff:     polling.Retry.MaxBackoff,
+			Jitter:         polling.Retry.Jitter,
+		},
+		Breaker: &scheduler.BreakerPolicy{
+			Threshold:   polling.Breaker.Threshold,
+			MaxInterval: polling.Breaker.MaxInterval,
+		},
+		OnBackoff: func(backoff time.Duration) {
+			metricsClient.UpdateTaskBackoff(task, backoff)
+		},
+	}
+}
+
+// pollingTasks returns names of enabled
+// tasks which poll qBittorrent
+func pollingTasks(polling config.PollingConfig) []string {
+	var tasks []string
+	if polling.Torrents.Enabled {
+		tasks = append(tasks, "torrents")
+	}
+	if polling.Transfer.Enabled {
+		tasks = append(tasks, "transfer")
+	}
+	if polling.Version.Enabled {
+		tasks = append(tasks, "version")
+	}
+	if polling.MainData.Enabled {
+		tasks = append(tasks, "maindata")
+	}
+	if polling.Categories.Enabled {
+		tasks = append(tasks, "categories")
+	}
+	if polling.Tags.Enabled {
+		tasks = append(tasks, "tags")
+	}
+	if polling.SpeedLimits.Enabled {
+		tasks = append(tasks, "speedlimits")
+	}
+	return tasks
 }
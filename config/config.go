@@ -2,11 +2,15 @@ package config
 
 import (
 	"fmt"
+	"os"
 	"qbittorrent_exporter/lib/log"
 	"qbittorrent_exporter/lib/parser"
 	"qbittorrent_exporter/validator"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -19,20 +23,179 @@ var (
 type Config struct {
 	QBittorrent QBittorrentConfig `yaml:"qBittorrent"`
 	Metrics     MetricsConfig     `yaml:"metrics"`
+	Polling     PollingConfig     `yaml:"polling"`
+	Health      HealthConfig      `yaml:"health"`
+	Problems    ProblemsConfig    `yaml:"problems"`
+	Goals       GoalsConfig       `yaml:"goals"`
+	Rolling     RollingConfig     `yaml:"rolling"`
+	Forecast    ForecastConfig    `yaml:"forecast"`
 	Global      GlobalConfig      `yaml:"global"`
 }
 
 type QBittorrentConfig struct {
 	BaseURL            string `yaml:"baseUrl" env:"QBE_URL"`
+	Auth               string `yaml:"auth" env:"QBE_AUTH,lower"`
 	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" env:"QBE_INSECURE_SKIP_VERIFY"`
 	Username           string `yaml:"username" env:"QBE_USERNAME"`
 	Password           string `yaml:"password" env:"QBE_PASSWORD"`
 	Timeout            int    `yaml:"timeout" env:"QBE_TIMEOUT"`
+	CAFile             string `yaml:"caFile" env:"QBE_CA_FILE"`
+	CertFile           string `yaml:"certFile" env:"QBE_CERT_FILE"`
+	KeyFile            string `yaml:"keyFile" env:"QBE_KEY_FILE"`
+	ServerName         string `yaml:"serverName" env:"QBE_SERVER_NAME"`
+	MinTLSVersion      string `yaml:"minTlsVersion" env:"QBE_MIN_TLS_VERSION"`
+
+	ReverseProxy ReverseProxyConfig `yaml:"reverseProxy" envPrefix:"QBE_REVERSE_PROXY_"`
+	Proxy        ProxyConfig        `yaml:"proxy" envPrefix:"QBE_PROXY_"`
+	Connection   ConnectionConfig   `yaml:"connection" envPrefix:"QBE_CONNECTION_"`
+}
+
+type ReverseProxyConfig struct {
+	Username        string            `yaml:"username" env:"USERNAME"`
+	Password        string            `yaml:"password" env:"PASSWORD"`
+	Headers         map[string]string `yaml:"headers"`
+	BearerTokenFile string            `yaml:"bearerTokenFile" env:"BEARER_TOKEN_FILE"`
+}
+
+type ProxyConfig struct {
+	// URL with http, https, socks5 or socks5h scheme,
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY are used when empty
+	URL      string `yaml:"url" env:"URL"`
+	Username string `yaml:"username" env:"USERNAME"`
+	Password string `yaml:"password" env:"PASSWORD"`
+}
+
+type ConnectionConfig struct {
+	KeepAlive           bool          `yaml:"keepAlive" env:"KEEP_ALIVE"`
+	MaxIdleConns        int           `yaml:"maxIdleConns" env:"MAX_IDLE_CONNS"`
+	MaxIdleConnsPerHost int           `yaml:"maxIdleConnsPerHost" env:"MAX_IDLE_CONNS_PER_HOST"`
+	IdleConnTimeout     time.Duration `yaml:"idleConnTimeout" env:"IDLE_CONN_TIMEOUT"`
+	HTTP2               bool          `yaml:"http2" env:"HTTP2"`
+	Compression         bool          `yaml:"compression" env:"COMPRESSION"`
 }
 
 type MetricsConfig struct {
 	Port    string `yaml:"port" env:"QBE_METRICS_PORT"`
 	UrlPath string `yaml:"urlPath" env:"QBE_METRICS_PATH"`
+	// ListenAddress takes priority over Port, e.g.
+	// "127.0.0.1:17171", "[::1]:17171" or "unix:/run/qbe.sock"
+	ListenAddress string `yaml:"listenAddress" env:"QBE_METRICS_LISTEN_ADDRESS"`
+	// SocketMode is octal permissions of the unix socket
+	SocketMode        string        `yaml:"socketMode" env:"QBE_METRICS_SOCKET_MODE"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" env:"QBE_METRICS_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"readTimeout" env:"QBE_METRICS_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" env:"QBE_METRICS_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" env:"QBE_METRICS_IDLE_TIMEOUT"`
+
+	Torrent TorrentMetricsConfig `yaml:"torrent" envPrefix:"QBE_METRICS_TORRENT_"`
+}
+
+// TorrentMetricsConfig toggles optional per-torrent metrics
+type TorrentMetricsConfig struct {
+	Size              bool `yaml:"size" env:"SIZE"`
+	TotalSize         bool `yaml:"totalSize" env:"TOTAL_SIZE"`
+	Uploaded          bool `yaml:"uploaded" env:"UPLOADED"`
+	UploadedSession   bool `yaml:"uploadedSession" env:"UPLOADED_SESSION"`
+	DownloadedSession bool `yaml:"downloadedSession" env:"DOWNLOADED_SESSION"`
+	Availability      bool `yaml:"availability" env:"AVAILABILITY"`
+	SeedingTime       bool `yaml:"seedingTime" env:"SEEDING_TIME"`
+	TimeActive        bool `yaml:"timeActive" env:"TIME_ACTIVE"`
+	AddedOn           bool `yaml:"addedOn" env:"ADDED_ON"`
+	CompletionOn      bool `yaml:"completionOn" env:"COMPLETION_ON"`
+	LastActivity      bool `yaml:"lastActivity" env:"LAST_ACTIVITY"`
+	NumComplete       bool `yaml:"numComplete" env:"NUM_COMPLETE"`
+	NumIncomplete     bool `yaml:"numIncomplete" env:"NUM_INCOMPLETE"`
+	DlLimit           bool `yaml:"dlLimit" env:"DL_LIMIT"`
+	UpLimit           bool `yaml:"upLimit" env:"UP_LIMIT"`
+	Priority          bool `yaml:"priority" env:"PRIORITY"`
+	MaxRatio          bool `yaml:"maxRatio" env:"MAX_RATIO"`
+	SeedingTimeLimit  bool `yaml:"seedingTimeLimit" env:"SEEDING_TIME_LIMIT"`
+}
+
+type PollingConfig struct {
+	Torrents    PollingTaskConfig `yaml:"torrents" envPrefix:"QBE_POLLING_TORRENTS_"`
+	Transfer    PollingTaskConfig `yaml:"transfer" envPrefix:"QBE_POLLING_TRANSFER_"`
+	Version     PollingTaskConfig `yaml:"version" envPrefix:"QBE_POLLING_VERSION_"`
+	State       PollingTaskConfig `yaml:"state" envPrefix:"QBE_POLLING_STATE_"`
+	MainData    PollingTaskConfig `yaml:"mainData" envPrefix:"QBE_POLLING_MAINDATA_"`
+	Categories  PollingTaskConfig `yaml:"categories" envPrefix:"QBE_POLLING_CATEGORIES_"`
+	Tags        PollingTaskConfig `yaml:"tags" envPrefix:"QBE_POLLING_TAGS_"`
+	SpeedLimits PollingTaskConfig `yaml:"speedLimits" envPrefix:"QBE_POLLING_SPEED_LIMITS_"`
+
+	StartJitter time.Duration `yaml:"startJitter" env:"QBE_POLLING_START_JITTER"`
+	Retry       RetryConfig   `yaml:"retry" envPrefix:"QBE_POLLING_RETRY_"`
+	Breaker     BreakerConfig `yaml:"breaker" envPrefix:"QBE_POLLING_BREAKER_"`
+}
+
+type PollingTaskConfig struct {
+	Enabled  bool          `yaml:"enabled" env:"ENABLED"`
+	Interval time.Duration `yaml:"interval" env:"INTERVAL"`
+}
+
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"maxAttempts" env:"MAX_ATTEMPTS"`
+	InitialBackoff time.Duration `yaml:"initialBackoff" env:"INITIAL_BACKOFF"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff" env:"MAX_BACKOFF"`
+	Jitter         float64       `yaml:"jitter" env:"JITTER"`
+}
+
+type BreakerConfig struct {
+	Threshold   int           `yaml:"threshold" env:"THRESHOLD"`
+	MaxInterval time.Duration `yaml:"maxInterval" env:"MAX_INTERVAL"`
+}
+
+type HealthConfig struct {
+	StaleIntervals   int           `yaml:"staleIntervals" env:"QBE_HEALTH_STALE_INTERVALS"`
+	OverdueThreshold time.Duration `yaml:"overdueThreshold" env:"QBE_HEALTH_OVERDUE_THRESHOLD"`
+}
+
+// ProblemsConfig sets rules of the problem torrents detector,
+// zero durations and speed disable their rules
+type ProblemsConfig struct {
+	Enabled bool `yaml:"enabled" env:"QBE_PROBLEMS_ENABLED"`
+	// Errored reports torrents in error and missingFiles states
+	Errored bool `yaml:"errored" env:"QBE_PROBLEMS_ERRORED"`
+	// StalledFor is how long a download may stay stalled
+	StalledFor time.Duration `yaml:"stalledFor" env:"QBE_PROBLEMS_STALLED_FOR"`
+	// SlowSpeed is download speed in bytes/s below which
+	// a download is slow after SlowFor
+	SlowSpeed int           `yaml:"slowSpeed" env:"QBE_PROBLEMS_SLOW_SPEED"`
+	SlowFor   time.Duration `yaml:"slowFor" env:"QBE_PROBLEMS_SLOW_FOR"`
+	// Unavailable reports downloads with availability below 1
+	Unavailable bool `yaml:"unavailable" env:"QBE_PROBLEMS_UNAVAILABLE"`
+	// NoPeersFor is how long a seeding torrent may have no peers
+	NoPeersFor time.Duration `yaml:"noPeersFor" env:"QBE_PROBLEMS_NO_PEERS_FOR"`
+}
+
+type GoalsConfig struct {
+	Enabled bool `yaml:"enabled" env:"QBE_GOALS_ENABLED"`
+	// Default applies to torrents without tracker rule and qBittorrent limits
+	Default  GoalConfig          `yaml:"default" envPrefix:"QBE_GOALS_DEFAULT_"`
+	Trackers []TrackerGoalConfig `yaml:"trackers"`
+}
+
+// GoalConfig is met when any of non-zero targets is met
+type GoalConfig struct {
+	Ratio       float64       `yaml:"ratio" env:"RATIO"`
+	SeedingTime time.Duration `yaml:"seedingTime" env:"SEEDING_TIME"`
+}
+
+type TrackerGoalConfig struct {
+	// Tracker is a host matched with its subdomains
+	// against the current tracker of the torrent
+	Tracker    string `yaml:"tracker"`
+	GoalConfig `yaml:",inline"`
+}
+
+// RollingConfig sets windows of per-torrent rolling statistics
+// kept in memory by the exporter
+type RollingConfig struct {
+	Enabled bool            `yaml:"enabled" env:"QBE_ROLLING_ENABLED"`
+	Windows []time.Duration `yaml:"windows"`
+}
+
+type ForecastConfig struct {
+	Enabled bool `yaml:"enabled" env:"QBE_FORECAST_ENABLED"`
 }
 
 type GlobalConfig struct {
@@ -54,8 +217,76 @@ func Get() Config {
 	return instance
 }
 
+// defaultConfig returns values used for options
+// which are absent from the config file
+func defaultConfig() Config {
+	return Config{
+		QBittorrent: QBittorrentConfig{
+			Connection: ConnectionConfig{
+				KeepAlive:           true,
+				MaxIdleConns:        10,
+				MaxIdleConnsPerHost: 4,
+				IdleConnTimeout:     90 * time.Second,
+				HTTP2:               true,
+				Compression:         true,
+			},
+		},
+		Metrics: MetricsConfig{
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+		},
+		Polling: PollingConfig{
+			Torrents:    PollingTaskConfig{Enabled: true, Interval: 30 * time.Second},
+			Transfer:    PollingTaskConfig{Enabled: true, Interval: 30 * time.Second},
+			Version:     PollingTaskConfig{Enabled: true, Interval: 10 * time.Minute},
+			State:       PollingTaskConfig{Enabled: true, Interval: 30 * time.Second},
+			MainData:    PollingTaskConfig{Enabled: true, Interval: 5 * time.Minute},
+			Categories:  PollingTaskConfig{Enabled: true, Interval: 5 * time.Minute},
+			Tags:        PollingTaskConfig{Enabled: true, Interval: 5 * time.Minute},
+			SpeedLimits: PollingTaskConfig{Enabled: true, Interval: 30 * time.Second},
+
+			StartJitter: 5 * time.Second,
+			Retry: RetryConfig{
+				MaxAttempts:    3,
+				InitialBackoff: time.Second,
+				MaxBackoff:     10 * time.Second,
+				Jitter:         0.2,
+			},
+			Breaker: BreakerConfig{
+				Threshold:   3,
+				MaxInterval: 30 * time.Minute,
+			},
+		},
+		Health: HealthConfig{
+			StaleIntervals:   3,
+			OverdueThreshold: time.Minute,
+		},
+		Problems: ProblemsConfig{
+			Enabled:     true,
+			Errored:     true,
+			StalledFor:  2 * time.Hour,
+			SlowSpeed:   10 * 1024,
+			SlowFor:     30 * time.Minute,
+			Unavailable: true,
+			NoPeersFor:  72 * time.Hour,
+		},
+		Goals: GoalsConfig{
+			Enabled: false,
+		},
+		Rolling: RollingConfig{
+			Enabled: false,
+			Windows: []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour},
+		},
+		Forecast: ForecastConfig{
+			Enabled: true,
+		},
+	}
+}
+
 func initializeConfig(path string) Config {
-	var cfg Config
+	cfg := defaultConfig()
 	if err := validator.ValidatePath(path, false); err != nil {
 		panic(err)
 	}
@@ -73,3 +304,115 @@ func ValidateMetricsPort(cfg Config) error {
 	}
 	return nil
 }
+
+// reservedPaths are served by the metrics server
+// regardless of metrics.urlPath
+var reservedPaths = []string{"/", "/healthz", "/readyz", "/debug/tasks"}
+
+func ValidateMetricsPath(cfg Config) error {
+	path := cfg.Metrics.UrlPath
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("invalid metrics urlPath: %q must start with /", path)
+	}
+	if slices.Contains(reservedPaths, path) {
+		return fmt.Errorf("invalid metrics urlPath: %q is reserved by QBE", path)
+	}
+	return nil
+}
+
+// Address returns address
+// the metrics server should bind to
+func (c MetricsConfig) Address() string {
+	if c.ListenAddress != "" {
+		return c.ListenAddress
+	}
+	return ":" + c.Port
+}
+
+// SocketFileMode parses octal permissions of the unix socket
+func (c MetricsConfig) SocketFileMode() (os.FileMode, error) {
+	if c.SocketMode == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(c.SocketMode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid metrics socket mode: %w", err)
+	}
+	return os.FileMode(mode), nil
+}
+
+const (
+	AuthNone     = "none"
+	AuthPassword = "password"
+)
+
+// AuthMode returns AuthNone when configured explicitly
+// or when no credentials are set, AuthPassword otherwise
+func (c QBittorrentConfig) AuthMode() (string, error) {
+	switch c.Auth {
+	case AuthNone, AuthPassword:
+		return c.Auth, nil
+	case "":
+		if c.Username == "" && c.Password == "" {
+			return AuthNone, nil
+		}
+		return AuthPassword, nil
+	default:
+		return "", fmt.Errorf("invalid qBittorrent auth: %s, expected %s or %s", c.Auth, AuthNone, AuthPassword)
+	}
+}
+
+func ValidatePolling(cfg Config) error {
+	timeout := time.Duration(cfg.QBittorrent.Timeout) * time.Second
+	tasks := map[string]PollingTaskConfig{
+		"torrents":    cfg.Polling.Torrents,
+		"transfer":    cfg.Polling.Transfer,
+		"version":     cfg.Polling.Version,
+		"maindata":    cfg.Polling.MainData,
+		"categories":  cfg.Polling.Categories,
+		"tags":        cfg.Polling.Tags,
+		"speedlimits": cfg.Polling.SpeedLimits,
+	}
+	breaker := cfg.Polling.Breaker
+	if breaker.Threshold > 0 && breaker.MaxInterval <= 0 {
+		return fmt.Errorf("invalid breaker max interval: %v must be positive", breaker.MaxInterval)
+	}
+	for name, task := range tasks {
+		if task.Enabled && task.Interval <= timeout {
+			return fmt.Errorf("invalid %s polling interval: %v must exceed qBittorrent timeout %v", name, task.Interval, timeout)
+		}
+		if task.Enabled && breaker.Threshold > 0 && breaker.MaxInterval < task.Interval {
+			return fmt.Errorf("invalid breaker max interval: %v must not be shorter than %s polling interval %v", breaker.MaxInterval, name, task.Interval)
+		}
+	}
+	if cfg.Polling.State.Enabled && cfg.Polling.State.Interval <= 0 {
+		return fmt.Errorf("invalid state polling interval: %v must be positive", cfg.Polling.State.Interval)
+	}
+	if cfg.Polling.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("invalid retry max attempts: %d must be at least 1", cfg.Polling.Retry.MaxAttempts)
+	}
+	if cfg.Polling.Retry.Jitter < 0 || cfg.Polling.Retry.Jitter > 1 {
+		return fmt.Errorf("invalid retry jitter: %v must be within [0, 1]", cfg.Polling.Retry.Jitter)
+	}
+	return nil
+}
+
+func ValidateProblems(cfg Config) error {
+	p := cfg.Problems
+	if p.StalledFor < 0 || p.SlowFor < 0 || p.NoPeersFor < 0 {
+		return fmt.Errorf("invalid problems durations: must not be negative")
+	}
+	if p.SlowSpeed < 0 {
+		return fmt.Errorf("invalid problems slow speed: %d must not be negative", p.SlowSpeed)
+	}
+	return nil
+}
+
+func ValidateRolling(cfg Config) error {
+	for _, window := range cfg.Rolling.Windows {
+		if window <= 0 {
+			return fmt.Errorf("invalid rolling window: %v must be positive", window)
+		}
+	}
+	return nil
+}
@@ -7,9 +7,19 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// loadEnvs fills struct fields tagged with `env`.
+// Nested structs tagged with `envPrefix` prepend
+// the prefix to env names of their fields.
+// Values are taken as is, `lower` option of the tag
+// lowercases values of enum fields, e.g. `env:"QBE_AUTH,lower"`
 func loadEnvs(v any) {
+	loadEnvsWithPrefix(v, "")
+}
+
+func loadEnvsWithPrefix(v any, prefix string) {
 	val := reflect.ValueOf(v)
 	typ := reflect.TypeOf(v)
 
@@ -28,7 +38,12 @@ func loadEnvs(v any) {
 		fieldValue := val.Field(i)
 
 		if envTag, ok := field.Tag.Lookup("env"); ok {
-			env := strings.ToLower(os.Getenv(envTag))
+			envTag, option, _ := strings.Cut(envTag, ",")
+			envTag = prefix + envTag
+			env := os.Getenv(envTag)
+			if option == "lower" {
+				env = strings.ToLower(env)
+			}
 			if len(env) != 0 && fieldValue.CanSet() {
 				if err := setFieldValue(fieldValue, env); err != nil {
 					log.Error(fmt.Sprintf("Failed to set %s from env %s: %v", field.Name, envTag, err))
@@ -39,12 +54,21 @@ func loadEnvs(v any) {
 		}
 
 		if fieldValue.Kind() == reflect.Struct {
-			loadEnvs(fieldValue.Addr().Interface())
+			loadEnvsWithPrefix(fieldValue.Addr().Interface(), prefix+field.Tag.Get("envPrefix"))
 		}
 	}
 }
 
 func setFieldValue(fieldValue reflect.Value, envTag string) error {
+	if fieldValue.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(envTag)
+		if err != nil {
+			return err
+		}
+		fieldValue.SetInt(int64(duration))
+		return nil
+	}
+
 	switch fieldValue.Kind() {
 	case reflect.String:
 		fieldValue.SetString(envTag)
@@ -55,7 +79,7 @@ func setFieldValue(fieldValue reflect.Value, envTag string) error {
 		}
 		fieldValue.SetInt(int64(intVal))
 	case reflect.Bool:
-		boolVal, err := strconv.ParseBool(envTag)
+		boolVal, err := strconv.ParseBool(strings.ToLower(envTag))
 		if err != nil {
 			return err
 		}
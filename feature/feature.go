@@ -10,11 +10,13 @@ type FeatureFlag int
 
 const (
 	TRANSIENT_STATE FeatureFlag = iota
+	LEGACY_TOTAL_GAUGES
 )
 
 func (f FeatureFlag) String() string {
 	var featureName = map[FeatureFlag]string{
-		TRANSIENT_STATE: "transient-state",
+		TRANSIENT_STATE:     "transient-state",
+		LEGACY_TOTAL_GAUGES: "legacy-total-gauges",
 	}
 	return featureName[f]
 }
@@ -49,6 +51,17 @@ func Get(flag FeatureFlag) bool {
 	return val
 }
 
+// All returns values of all set feature flags by name
+func All() map[string]bool {
+	lock.Lock()
+	defer lock.Unlock()
+	all := make(map[string]bool, len(flags))
+	for ff, val := range flags {
+		all[ff.String()] = val
+	}
+	return all
+}
+
 func Set(flag FeatureFlag, value bool) {
 	lock.Lock()
 	defer lock.Unlock()
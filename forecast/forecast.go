@@ -0,0 +1,112 @@
+package forecast
+
+import (
+	"math"
+	"qbittorrent_exporter/rolling"
+	"qbittorrent_exporter/types"
+	"sync"
+	"time"
+)
+
+// Forecast of the download queue, ETAs are
+// seconds and +Inf when nothing is downloaded
+type Forecast struct {
+	// Remaining is data still to be written per save path
+	Remaining map[string]int64
+	// FreeSpace is valid when HasFreeSpace is set
+	FreeSpace    int64
+	HasFreeSpace bool
+	QueueETA     float64
+	DiskFullETA  float64
+}
+
+// Forecaster combines torrents with free disk space
+// reported by the last sync of the main data
+type Forecaster struct {
+	mu           sync.Mutex
+	freeSpace    int64
+	hasFreeSpace bool
+}
+
+func NewForecaster() *Forecaster {
+	return &Forecaster{}
+}
+
+func (f *Forecaster) UpdateFreeSpace(bytes int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.freeSpace = bytes
+	f.hasFreeSpace = true
+}
+
+// Forecast uses download rates of the shortest rolling window
+// covering some time, instantaneous speed of torrents otherwise
+func (f *Forecaster) Forecast(torrents []types.Torrent, rates []rolling.Rates) Forecast {
+	f.mu.Lock()
+	fc := Forecast{
+		Remaining:    map[string]int64{},
+		FreeSpace:    f.freeSpace,
+		HasFreeSpace: f.hasFreeSpace,
+		QueueETA:     math.Inf(1),
+		DiskFullETA:  math.Inf(1),
+	}
+	f.mu.Unlock()
+
+	downloadRates := shortestWindowRates(rates)
+	var remaining int64
+	var rate float64
+	for _, torrent := range torrents {
+		if !isQueued(torrent.State) {
+			continue
+		}
+		fc.Remaining[torrent.SavePath] += torrent.AmountLeft
+		remaining += torrent.AmountLeft
+		if r, ok := downloadRates[torrent.Hash]; ok {
+			rate += r
+		} else {
+			rate += float64(torrent.Dlspeed)
+		}
+	}
+
+	switch {
+	case remaining == 0:
+		fc.QueueETA = 0
+	case rate > 0:
+		fc.QueueETA = float64(remaining) / rate
+	}
+	// the disk fills only when the queue doesn't fit
+	if fc.HasFreeSpace && remaining > fc.FreeSpace && rate > 0 {
+		fc.DiskFullETA = float64(max(fc.FreeSpace, 0)) / rate
+	}
+
+	return fc
+}
+
+func shortestWindowRates(rates []rolling.Rates) map[string]float64 {
+	downloadRates := map[string]float64{}
+	windows := map[string]time.Duration{}
+	for _, r := range rates {
+		// first samples of a torrent cover no time yet
+		if r.Coverage <= 0 {
+			continue
+		}
+		if window, ok := windows[r.Hash]; ok && window <= r.Window {
+			continue
+		}
+		windows[r.Hash] = r.Window
+		downloadRates[r.Hash] = r.DownloadRate
+	}
+	return downloadRates
+}
+
+// isQueued reports states of torrents which
+// are going to write data to the disk
+func isQueued(state string) bool {
+	switch state {
+	case types.StateDownloading, types.StateForcedDL, types.StateStalledDL,
+		types.StateMetaDL, types.StateForcedMetaDL, types.StateQueuedDL,
+		types.StateCheckingDL, types.StateAllocating:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package forecast
+
+import (
+	"math"
+	"qbittorrent_exporter/rolling"
+	"qbittorrent_exporter/types"
+	"testing"
+	"time"
+)
+
+func TestForecast(t *testing.T) {
+	inf := math.Inf(1)
+	downloading := func(hash string, left, speed int64) types.Torrent {
+		return types.Torrent{Hash: hash, State: types.StateDownloading, SavePath: "/data", AmountLeft: left, Dlspeed: speed}
+	}
+	tests := []struct {
+		name          string
+		torrents      []types.Torrent
+		rates         []rolling.Rates
+		freeSpace     int64
+		hasFreeSpace  bool
+		wantQueueETA  float64
+		wantDiskETA   float64
+		wantRemaining int64
+	}{
+		{
+			name:        "empty queue",
+			wantDiskETA: inf,
+		},
+		{
+			name:          "instantaneous speed",
+			torrents:      []types.Torrent{downloading("a", 1000, 10), downloading("b", 1000, 10)},
+			wantQueueETA:  100,
+			wantDiskETA:   inf,
+			wantRemaining: 2000,
+		},
+		{
+			name:          "nothing downloaded",
+			torrents:      []types.Torrent{downloading("a", 1000, 0)},
+			wantQueueETA:  inf,
+			wantDiskETA:   inf,
+			wantRemaining: 1000,
+		},
+		{
+			name:     "shortest covered window wins",
+			torrents: []types.Torrent{downloading("a", 1000, 1)},
+			rates: []rolling.Rates{
+				{Hash: "a", Window: time.Hour, Coverage: time.Hour, DownloadRate: 5},
+				{Hash: "a", Window: time.Minute, Coverage: time.Minute, DownloadRate: 50},
+				{Hash: "a", Window: 5 * time.Minute, Coverage: 5 * time.Minute, DownloadRate: 20},
+			},
+			wantQueueETA:  20,
+			wantDiskETA:   inf,
+			wantRemaining: 1000,
+		},
+		{
+			name:     "uncovered window falls back to speed",
+			torrents: []types.Torrent{downloading("a", 1000, 10)},
+			rates: []rolling.Rates{
+				{Hash: "a", Window: time.Minute, DownloadRate: 500},
+			},
+			wantQueueETA:  100,
+			wantDiskETA:   inf,
+			wantRemaining: 1000,
+		},
+		{
+			name: "only queued torrents count",
+			torrents: []types.Torrent{
+				downloading("a", 1000, 10),
+				{Hash: "b", State: types.StateStoppedDL, AmountLeft: 5000, Dlspeed: 10},
+			},
+			wantQueueETA:  100,
+			wantDiskETA:   inf,
+			wantRemaining: 1000,
+		},
+		{
+			name:          "queue fits on the disk",
+			torrents:      []types.Torrent{downloading("a", 1000, 10)},
+			freeSpace:     2000,
+			hasFreeSpace:  true,
+			wantQueueETA:  100,
+			wantDiskETA:   inf,
+			wantRemaining: 1000,
+		},
+		{
+			name:          "disk fills first",
+			torrents:      []types.Torrent{downloading("a", 1000, 10)},
+			freeSpace:     300,
+			hasFreeSpace:  true,
+			wantQueueETA:  100,
+			wantDiskETA:   30,
+			wantRemaining: 1000,
+		},
+		{
+			name:          "disk already full",
+			torrents:      []types.Torrent{downloading("a", 1000, 10)},
+			freeSpace:     -100,
+			hasFreeSpace:  true,
+			wantQueueETA:  100,
+			wantDiskETA:   0,
+			wantRemaining: 1000,
+		},
+		{
+			name:          "free space unknown",
+			torrents:      []types.Torrent{downloading("a", 1000, 10)},
+			wantQueueETA:  100,
+			wantDiskETA:   inf,
+			wantRemaining: 1000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewForecaster()
+			if tt.hasFreeSpace {
+				f.UpdateFreeSpace(tt.freeSpace)
+			}
+			fc := f.Forecast(tt.torrents, tt.rates)
+			if fc.QueueETA != tt.wantQueueETA {
+				t.Errorf("QueueETA = %v, want %v", fc.QueueETA, tt.wantQueueETA)
+			}
+			if fc.DiskFullETA != tt.wantDiskETA {
+				t.Errorf("DiskFullETA = %v, want %v", fc.DiskFullETA, tt.wantDiskETA)
+			}
+			if got := fc.Remaining["/data"]; got != tt.wantRemaining {
+				t.Errorf("Remaining = %d, want %d", got, tt.wantRemaining)
+			}
+		})
+	}
+}
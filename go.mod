@@ -4,6 +4,7 @@ go 1.24.1
 
 require (
 	github.com/prometheus/client_golang v1.22.0
+	golang.org/x/crypto v0.33.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -0,0 +1,158 @@
+package goals
+
+import (
+	"math"
+	"net/url"
+	"qbittorrent_exporter/config"
+	"qbittorrent_exporter/types"
+	"strings"
+	"time"
+)
+
+// Status is progress of the torrent toward its seeding goal
+type Status struct {
+	Hash    string
+	Name    string
+	Tracker string
+	// Progress is within [0, 1], the best of ratio and seeding time
+	Progress float64
+	Met      bool
+	// ETA is seconds until the goal is met at the current
+	// upload speed, +Inf if the torrent makes no progress
+	ETA float64
+	// AtRisk is set when the goal is not met and won't be
+	AtRisk bool
+}
+
+type goal struct {
+	ratio       float64
+	seedingTime time.Duration
+}
+
+func (g goal) isZero() bool {
+	return g.ratio <= 0 && g.seedingTime <= 0
+}
+
+// Evaluator resolves goals of torrents from tracker rules,
+// limits set in qBittorrent and the default goal, in this order
+type Evaluator struct {
+	cfg config.GoalsConfig
+}
+
+func NewEvaluator(cfg config.GoalsConfig) *Evaluator {
+	return &Evaluator{cfg: cfg}
+}
+
+// Evaluate returns statuses of completed torrents having a goal,
+// downloads make no progress toward it yet
+func (e *Evaluator) Evaluate(torrents []types.Torrent) []Status {
+	var statuses []Status
+	for _, torrent := range torrents {
+		if torrent.Progress < 1 {
+			continue
+		}
+		tracker := trackerHost(torrent.Tracker)
+		g := e.resolve(torrent, tracker)
+		if g.isZero() {
+			continue
+		}
+		statuses = append(statuses, evaluate(torrent, tracker, g))
+	}
+	return statuses
+}
+
+func (e *Evaluator) resolve(torrent types.Torrent, tracker string) goal {
+	for _, rule := range e.cfg.Trackers {
+		if matchTracker(tracker, rule.Tracker) {
+			return goal{ratio: rule.Ratio, seedingTime: rule.SeedingTime}
+		}
+	}
+
+	// qBittorrent reports -1 for no limit and -2 for the global limit,
+	// max_* fields hold the limits in effect
+	var g goal
+	if torrent.RatioLimit > 0 {
+		g.ratio = torrent.RatioLimit
+	} else if torrent.MaxRatio > 0 {
+		g.ratio = torrent.MaxRatio
+	}
+	if torrent.SeedingTimeLimit > 0 {
+		g.seedingTime = time.Duration(torrent.SeedingTimeLimit) * time.Minute
+	} else if torrent.MaxSeedingTime > 0 {
+		g.seedingTime = time.Duration(torrent.MaxSeedingTime) * time.Minute
+	}
+	if !g.isZero() {
+		return g
+	}
+
+	return goal{ratio: e.cfg.Default.Ratio, seedingTime: e.cfg.Default.SeedingTime}
+}
+
+// evaluate treats the goal as met when any of its targets is met,
+// as trackers usually require either ratio or seeding time
+func evaluate(torrent types.Torrent, tracker string, g goal) Status {
+	status := Status{
+		Hash:    torrent.Hash,
+		Name:    torrent.Name,
+		Tracker: tracker,
+		ETA:     math.Inf(1),
+	}
+
+	if g.ratio > 0 {
+		progress := min(torrent.Ratio/g.ratio, 1)
+		status.Progress = max(status.Progress, progress)
+		if progress >= 1 {
+			status.ETA = 0
+		} else {
+			status.ETA = min(status.ETA, ratioETA(torrent, g.ratio))
+		}
+	}
+	if g.seedingTime > 0 {
+		seedingTime := time.Duration(torrent.SeedingTime) * time.Second
+		progress := min(seedingTime.Seconds()/g.seedingTime.Seconds(), 1)
+		status.Progress = max(status.Progress, progress)
+		switch {
+		case progress >= 1:
+			status.ETA = 0
+		case types.IsSeeding(torrent.State):
+			status.ETA = min(status.ETA, (g.seedingTime - seedingTime).Seconds())
+		}
+	}
+
+	status.Met = status.Progress >= 1
+	status.AtRisk = math.IsInf(status.ETA, 1)
+	return status
+}
+
+func ratioETA(torrent types.Torrent, target float64) float64 {
+	needed := target*ratioBase(torrent) - float64(torrent.Uploaded)
+	if needed <= 0 || torrent.Upspeed <= 0 {
+		return math.Inf(1)
+	}
+	return needed / float64(torrent.Upspeed)
+}
+
+// ratioBase returns the denominator of the share ratio, qBittorrent
+// uses the completed size when nothing was downloaded, e.g. cross-seeding
+func ratioBase(torrent types.Torrent) float64 {
+	if torrent.Ratio > 0 {
+		return float64(torrent.Uploaded) / torrent.Ratio
+	}
+	return float64(max(torrent.Downloaded, torrent.Completed))
+}
+
+func trackerHost(tracker string) string {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// matchTracker matches the host and its subdomains
+func matchTracker(host, rule string) bool {
+	if host == "" || rule == "" {
+		return false
+	}
+	return host == rule || strings.HasSuffix(host, "."+rule)
+}
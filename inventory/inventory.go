@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"qbittorrent_exporter/types"
+	"strings"
+	"sync"
+)
+
+// Group aggregates torrents of a category or a tag
+type Group struct {
+	Torrents int
+	Size     int64
+	DlSpeed  int64
+	UpSpeed  int64
+}
+
+type Summary struct {
+	Categories    map[string]Group
+	Tags          map[string]Group
+	Uncategorized int
+	Untagged      int
+}
+
+// Inventory holds categories and tags known to qBittorrent,
+// so groups without torrents are summarized as well
+type Inventory struct {
+	mu         sync.Mutex
+	categories []string
+	tags       []string
+}
+
+func NewInventory() *Inventory {
+	return &Inventory{}
+}
+
+func (inv *Inventory) UpdateCategories(categories map[string]types.Category) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.categories = inv.categories[:0]
+	for name := range categories {
+		inv.categories = append(inv.categories, name)
+	}
+}
+
+func (inv *Inventory) UpdateTags(tags []string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.tags = append(inv.tags[:0], tags...)
+}
+
+// Summarize groups torrents by category and tag,
+// torrents missing from the inventory are grouped too
+func (inv *Inventory) Summarize(torrents []types.Torrent) Summary {
+	summary := Summary{
+		Categories: map[string]Group{},
+		Tags:       map[string]Group{},
+	}
+
+	inv.mu.Lock()
+	for _, category := range inv.categories {
+		summary.Categories[category] = Group{}
+	}
+	for _, tag := range inv.tags {
+		summary.Tags[tag] = Group{}
+	}
+	inv.mu.Unlock()
+
+	for _, torrent := range torrents {
+		if torrent.Category == "" {
+			summary.Uncategorized++
+		} else {
+			summary.Categories[torrent.Category] = summary.Categories[torrent.Category].add(torrent)
+		}
+
+		tags := ParseTags(torrent.Tags)
+		if len(tags) == 0 {
+			summary.Untagged++
+		}
+		for _, tag := range tags {
+			summary.Tags[tag] = summary.Tags[tag].add(torrent)
+		}
+	}
+
+	return summary
+}
+
+func (g Group) add(torrent types.Torrent) Group {
+	g.Torrents++
+	g.Size += torrent.Size
+	g.DlSpeed += torrent.Dlspeed
+	g.UpSpeed += torrent.Upspeed
+	return g
+}
+
+// ParseTags splits comma separated tags of a torrent
+func ParseTags(tags string) []string {
+	var parsed []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			parsed = append(parsed, tag)
+		}
+	}
+	return parsed
+}
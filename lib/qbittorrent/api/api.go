@@ -2,13 +2,19 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"qbittorrent_exporter/lib/log"
 	"qbittorrent_exporter/types"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -16,27 +22,51 @@ const (
 
 	authLogin = apiV2 + "/auth/login"
 
-	torrentsInfo = apiV2 + "/torrents/info"
-	transferInfo = apiV2 + "/transfer/info"
-	appVersion   = apiV2 + "/app/version"
+	torrentsInfo  = apiV2 + "/torrents/info"
+	transferInfo  = apiV2 + "/transfer/info"
+	appVersion    = apiV2 + "/app/version"
+	webapiVersion = apiV2 + "/app/webapiVersion"
+	syncMaindata  = apiV2 + "/sync/maindata"
+	categories    = apiV2 + "/torrents/categories"
+	tags          = apiV2 + "/torrents/tags"
+
+	speedLimitsMode = apiV2 + "/transfer/speedLimitsMode"
+	appPreferences  = apiV2 + "/app/preferences"
 
 	headerContentType      = "Content-Type"
 	headerReferer          = "Referer"
+	headerAuthorization    = "Authorization"
 	contentTypeFormEncoded = "application/x-www-form-urlencoded"
 	contentTypeJSON        = "application/json"
 	contentTypePlain       = "text/plain; charset=UTF-8"
+
+	loginOk    = "Ok."
+	loginFails = "Fails."
 )
 
 type QBittorrentAPI struct {
-	baseURL   string
-	sidCookie *http.Cookie
-	client    *http.Client
+	baseURL      string
+	client       *http.Client
+	reverseProxy *ReverseProxyAuth
+	// credentials are kept to log in again when the session expires
+	credentials url.Values
+
+	mu            sync.Mutex
+	sidCookie     *http.Cookie
+	noAuth        bool
+	webAPIVersion APIVersion
+	unsupported   map[string]bool
+	// notFound counts consecutive 404 responses by endpoint
+	notFound map[string]int
+	// mainDataRid is the response id of the last main data sync
+	mainDataRid int64
 }
 
 type QBittorrentAPIOpts struct {
-	BaseURL     string
-	Credentials *QBittorrentCredentials
-	HttpClient  *http.Client
+	BaseURL      string
+	Credentials  *QBittorrentCredentials
+	HttpClient   *http.Client
+	ReverseProxy *ReverseProxyAuth
 }
 
 type QBittorrentCredentials struct {
@@ -44,40 +74,82 @@ type QBittorrentCredentials struct {
 	Password string
 }
 
+// ReverseProxyAuth holds credentials required by a reverse proxy
+// in front of qBittorrent, sent with every request
+type ReverseProxyAuth struct {
+	Username string
+	Password string
+	// Headers are static headers, e.g. CF-Access-Client-Id,
+	// they may also override Referer and Origin
+	Headers map[string]string
+	// BearerTokenFile is read on every request
+	// so rotated tokens are picked up
+	BearerTokenFile string
+}
+
+// NewQBittorrentAPI logs in with the given credentials,
+// nil Credentials skip login for qBittorrent with
+// authentication bypass for localhost or whitelisted subnets
 func NewQBittorrentAPI(o *QBittorrentAPIOpts) (*QBittorrentAPI, error) {
+	return NewQBittorrentAPIContext(context.Background(), o)
+}
+
+func NewQBittorrentAPIContext(ctx context.Context, o *QBittorrentAPIOpts) (*QBittorrentAPI, error) {
+	if rp := o.ReverseProxy; rp != nil && rp.Username != "" && rp.BearerTokenFile != "" {
+		return nil, fmt.Errorf("reverse proxy basic auth and bearer token are mutually exclusive")
+	}
+
 	api := &QBittorrentAPI{
-		baseURL: o.BaseURL,
-		client:  o.HttpClient,
+		baseURL:      o.BaseURL,
+		client:       o.HttpClient,
+		reverseProxy: o.ReverseProxy,
+		unsupported:  map[string]bool{},
+		notFound:     map[string]int{},
 	}
 
-	credentials := url.Values{
-		"username": {o.Credentials.Username},
-		"password": {o.Credentials.Password},
+	if o.Credentials == nil {
+		api.noAuth = true
+	} else {
+		api.credentials = url.Values{
+			"username": {o.Credentials.Username},
+			"password": {o.Credentials.Password},
+		}
+		o.Credentials = &QBittorrentCredentials{}
+
+		if err := api.LoginContext(ctx, api.credentials); err != nil {
+			return nil, err
+		}
 	}
-	o.Credentials = &QBittorrentCredentials{}
 
-	if err := api.Login(credentials); err != nil {
-		return nil, err
+	if err := api.detectWebAPIVersion(ctx); err != nil {
+		log.Warn("Unable to detect Web API version, assuming all endpoints are supported: " + err.Error())
+	} else {
+		log.Info("Detected qBittorrent Web API version " + api.WebAPIVersion().String())
 	}
 
 	return api, nil
 }
 
 func (api *QBittorrentAPI) Login(credentials url.Values) error {
-	var sidCookie *http.Cookie
+	return api.LoginContext(context.Background(), credentials)
+}
 
+func (api *QBittorrentAPI) LoginContext(ctx context.Context, credentials url.Values) error {
 	loginURL := api.baseURL + authLogin
 	if err := ValidateURL(loginURL); err != nil {
 		return fmt.Errorf("invalid login URL: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", loginURL, strings.NewReader(credentials.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", loginURL, strings.NewReader(credentials.Encode()))
 	if err != nil {
 		return fmt.Errorf("create login request: %w", err)
 	}
 
 	req.Header.Set(headerContentType, contentTypeFormEncoded)
 	req.Header.Set(headerReferer, api.baseURL)
+	if err := api.setReverseProxyAuth(req); err != nil {
+		return err
+	}
 
 	resp, err := api.client.Do(req)
 	if err != nil {
@@ -85,34 +157,122 @@ func (api *QBittorrentAPI) Login(credentials url.Values) error {
 	}
 	defer resp.Body.Close()
 
-	cookies := resp.Cookies()
-	for _, cookie := range cookies {
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("login forbidden: IP is banned after too many failed login attempts: %w", ErrUnauthorized)
+	case resp.StatusCode == http.StatusNotFound:
+		// Web API v2 is available since qBittorrent 4.1
+		return fmt.Errorf("login endpoint not found, qBittorrent older than 4.1 or wrong baseUrl: %w", ErrUnsupportedAPIVersion)
+	case resp.StatusCode != http.StatusOK:
+		return newStatusError(authLogin, resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return fmt.Errorf("read login response: %w", err)
+	}
+	switch result := strings.TrimSpace(string(body)); result {
+	case loginOk:
+	case loginFails:
+		return fmt.Errorf("login failed: invalid username or password: %w", ErrUnauthorized)
+	default:
+		return fmt.Errorf("unexpected login response: %q", result)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for _, cookie := range resp.Cookies() {
 		if cookie.Name == "SID" {
-			sidCookie = cookie
-			break
+			api.sidCookie = cookie
+			return nil
 		}
 	}
 
-	if sidCookie == nil {
-		return fmt.Errorf("SID cookie not found in login response")
+	// qBittorrent with authentication bypass
+	// may accept login without issuing a session
+	log.Warn("SID cookie not found in successful login response, continuing without session")
+	api.noAuth = true
+	return nil
+}
+
+// IsLoggedIn reports whether the exporter has a session,
+// false after qBittorrent rejected it until login succeeds again
+func (api *QBittorrentAPI) IsLoggedIn() bool {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.noAuth || api.sidCookie != nil
+}
+
+func (api *QBittorrentAPI) session() *http.Cookie {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.sidCookie
+}
+
+// relogin logs in again after qBittorrent rejected the session,
+// e.g. when it expired or qBittorrent restarted. Requests failed
+// with the same session log in only once
+func (api *QBittorrentAPI) relogin(ctx context.Context, rejected *http.Cookie) error {
+	api.mu.Lock()
+	if api.sidCookie != rejected {
+		api.mu.Unlock()
+		return nil
+	}
+	api.sidCookie = nil
+	api.mu.Unlock()
+
+	log.Warn("qBittorrent rejected the session, logging in again")
+	if err := api.LoginContext(ctx, api.credentials); err != nil {
+		return err
 	}
-	api.sidCookie = sidCookie
+	api.resetUnsupported()
 	return nil
 }
 
-func (api *QBittorrentAPI) doAuthenticatedGet(endpoint, contentType string) ([]byte, error) {
+func (api *QBittorrentAPI) doAuthenticatedGet(ctx context.Context, endpoint, contentType string) ([]byte, error) {
+	return api.doAuthenticatedGetQuery(ctx, endpoint, nil, contentType)
+}
+
+func (api *QBittorrentAPI) doAuthenticatedGetQuery(ctx context.Context, endpoint string, query url.Values, contentType string) ([]byte, error) {
+	if err := api.checkSupported(endpoint); err != nil {
+		return nil, err
+	}
+
 	url := api.baseURL + endpoint
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
 	if err := ValidateURL(url); err != nil {
 		return nil, fmt.Errorf("invalid URL for %s: %w", endpoint, err)
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	sid := api.session()
+	body, err := api.doGet(ctx, endpoint, url, contentType, sid)
+	// qBittorrent responds 403 to requests with an unknown session,
+	// 401 comes from a reverse proxy and isn't fixed by login
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusForbidden && api.credentials != nil {
+		if err := api.relogin(ctx, sid); err != nil {
+			return nil, err
+		}
+		body, err = api.doGet(ctx, endpoint, url, contentType, api.session())
+	}
+	return body, err
+}
+
+func (api *QBittorrentAPI) doGet(ctx context.Context, endpoint, url, contentType string, sid *http.Cookie) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request for %s: %w", endpoint, err)
 	}
 
-	req.AddCookie(api.sidCookie)
+	if sid != nil {
+		req.AddCookie(sid)
+	}
 	req.Header.Set(headerContentType, contentType)
+	if err := api.setReverseProxyAuth(req); err != nil {
+		return nil, err
+	}
 
 	resp, err := api.client.Do(req)
 	if err != nil {
@@ -120,6 +280,13 @@ func (api *QBittorrentAPI) doAuthenticatedGet(endpoint, contentType string) ([]b
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, api.markUnsupported(endpoint, newStatusError(endpoint, resp))
+	}
+	api.mu.Lock()
+	delete(api.notFound, endpoint)
+	api.mu.Unlock()
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("read response body for %s: %w", endpoint, err)
@@ -128,38 +295,186 @@ func (api *QBittorrentAPI) doAuthenticatedGet(endpoint, contentType string) ([]b
 	return body, nil
 }
 
+// setReverseProxyAuth adds credentials of the reverse proxy,
+// qBittorrent itself doesn't use Authorization header
+func (api *QBittorrentAPI) setReverseProxyAuth(req *http.Request) error {
+	rp := api.reverseProxy
+	if rp == nil {
+		return nil
+	}
+
+	if rp.Username != "" {
+		req.SetBasicAuth(rp.Username, rp.Password)
+	}
+	if rp.BearerTokenFile != "" {
+		token, err := os.ReadFile(rp.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("read bearer token: %w", err)
+		}
+		req.Header.Set(headerAuthorization, "Bearer "+strings.TrimSpace(string(token)))
+	}
+	for name, value := range rp.Headers {
+		req.Header.Set(name, value)
+	}
+	return nil
+}
+
 func (api *QBittorrentAPI) TorrentsInfo() ([]types.Torrent, error) {
+	return api.TorrentsInfoContext(context.Background())
+}
+
+func (api *QBittorrentAPI) TorrentsInfoContext(ctx context.Context) ([]types.Torrent, error) {
 	var torrents []types.Torrent
 
-	body, err := api.doAuthenticatedGet(torrentsInfo, contentTypeJSON)
+	body, err := api.doAuthenticatedGet(ctx, torrentsInfo, contentTypeJSON)
 	if err != nil {
 		return torrents, err
 	}
 
 	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&torrents); err != nil {
-		return torrents, fmt.Errorf("decode torrents info: %w", err)
+		return torrents, newDecodeError(torrentsInfo, body, err)
+	}
+	for i := range torrents {
+		torrents[i].State = types.NormalizeState(torrents[i].State)
 	}
 
 	return torrents, nil
 }
 
 func (api *QBittorrentAPI) TransferInfo() (types.Transfer, error) {
+	return api.TransferInfoContext(context.Background())
+}
+
+func (api *QBittorrentAPI) TransferInfoContext(ctx context.Context) (types.Transfer, error) {
 	var transfer types.Transfer
 
-	body, err := api.doAuthenticatedGet(transferInfo, contentTypeJSON)
+	body, err := api.doAuthenticatedGet(ctx, transferInfo, contentTypeJSON)
 	if err != nil {
 		return transfer, err
 	}
 
 	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&transfer); err != nil {
-		return transfer, fmt.Errorf("decode transfer info: %w", err)
+		return transfer, newDecodeError(transferInfo, body, err)
 	}
 
 	return transfer, nil
 }
 
+func (api *QBittorrentAPI) MainData() (types.MainData, error) {
+	return api.MainDataContext(context.Background())
+}
+
+// MainDataContext requests changes since the previous call,
+// fields of the response are set only when they changed
+func (api *QBittorrentAPI) MainDataContext(ctx context.Context) (types.MainData, error) {
+	var mainData types.MainData
+
+	api.mu.Lock()
+	rid := api.mainDataRid
+	api.mu.Unlock()
+
+	query := url.Values{"rid": {strconv.FormatInt(rid, 10)}}
+	body, err := api.doAuthenticatedGetQuery(ctx, syncMaindata, query, contentTypeJSON)
+	if err != nil {
+		return mainData, err
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&mainData); err != nil {
+		return mainData, newDecodeError(syncMaindata, body, err)
+	}
+
+	api.mu.Lock()
+	api.mainDataRid = mainData.Rid
+	api.mu.Unlock()
+
+	return mainData, nil
+}
+
+func (api *QBittorrentAPI) Categories() (map[string]types.Category, error) {
+	return api.CategoriesContext(context.Background())
+}
+
+// CategoriesContext returns categories keyed by name
+func (api *QBittorrentAPI) CategoriesContext(ctx context.Context) (map[string]types.Category, error) {
+	var categoriesByName map[string]types.Category
+
+	body, err := api.doAuthenticatedGet(ctx, categories, contentTypeJSON)
+	if err != nil {
+		return categoriesByName, err
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&categoriesByName); err != nil {
+		return categoriesByName, newDecodeError(categories, body, err)
+	}
+
+	return categoriesByName, nil
+}
+
+func (api *QBittorrentAPI) Tags() ([]string, error) {
+	return api.TagsContext(context.Background())
+}
+
+func (api *QBittorrentAPI) TagsContext(ctx context.Context) ([]string, error) {
+	var tagNames []string
+
+	body, err := api.doAuthenticatedGet(ctx, tags, contentTypeJSON)
+	if err != nil {
+		return tagNames, err
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&tagNames); err != nil {
+		return tagNames, newDecodeError(tags, body, err)
+	}
+
+	return tagNames, nil
+}
+
+func (api *QBittorrentAPI) SpeedLimitsMode() (bool, error) {
+	return api.SpeedLimitsModeContext(context.Background())
+}
+
+// SpeedLimitsModeContext reports whether alternative speed limits are active
+func (api *QBittorrentAPI) SpeedLimitsModeContext(ctx context.Context) (bool, error) {
+	body, err := api.doAuthenticatedGet(ctx, speedLimitsMode, contentTypePlain)
+	if err != nil {
+		return false, err
+	}
+
+	switch mode := strings.TrimSpace(string(body)); mode {
+	case "1":
+		return true, nil
+	case "0":
+		return false, nil
+	default:
+		return false, newDecodeError(speedLimitsMode, body, fmt.Errorf("unexpected speed limits mode: %q", mode))
+	}
+}
+
+func (api *QBittorrentAPI) Preferences() (types.Preferences, error) {
+	return api.PreferencesContext(context.Background())
+}
+
+func (api *QBittorrentAPI) PreferencesContext(ctx context.Context) (types.Preferences, error) {
+	var preferences types.Preferences
+
+	body, err := api.doAuthenticatedGet(ctx, appPreferences, contentTypeJSON)
+	if err != nil {
+		return preferences, err
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&preferences); err != nil {
+		return preferences, newDecodeError(appPreferences, body, err)
+	}
+
+	return preferences, nil
+}
+
 func (api *QBittorrentAPI) AppVersion() (string, error) {
-	body, err := api.doAuthenticatedGet(appVersion, contentTypePlain)
+	return api.AppVersionContext(context.Background())
+}
+
+func (api *QBittorrentAPI) AppVersionContext(ctx context.Context) (string, error) {
+	body, err := api.doAuthenticatedGet(ctx, appVersion, contentTypePlain)
 	if err != nil {
 		return "", err
 	}
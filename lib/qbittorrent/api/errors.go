@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"unicode/utf8"
+)
+
+const excerptLength = 128
+
+var (
+	ErrUnauthorized          = errors.New("unauthorized")
+	ErrNotFound              = errors.New("not found")
+	ErrServerError           = errors.New("server error")
+	ErrUnsupportedAPIVersion = errors.New("unsupported Web API version")
+	ErrDecode                = errors.New("decode error")
+)
+
+// StatusError is returned for non-2xx responses,
+// check the cause with errors.Is
+type StatusError struct {
+	Endpoint   string
+	StatusCode int
+	Status     string
+	cause      error
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s: unexpected status %s", e.Endpoint, e.Status)
+	if e.cause != nil {
+		msg = fmt.Sprintf("%s: %v (%s)", e.Endpoint, e.cause, e.Status)
+	}
+	switch e.cause {
+	case ErrUnauthorized:
+		msg += ", session expired or login is required"
+	case ErrNotFound:
+		msg += ", check baseUrl and qBittorrent version"
+	}
+	return msg
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.cause
+}
+
+// DecodeError holds a truncated excerpt
+// of the body which failed to decode
+type DecodeError struct {
+	Endpoint string
+	Excerpt  string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: decode response: %v, body: %q", e.Endpoint, e.Err, e.Excerpt)
+}
+
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func newStatusError(endpoint string, resp *http.Response) error {
+	e := &StatusError{
+		Endpoint:   endpoint,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		e.cause = ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		e.cause = ErrNotFound
+	case resp.StatusCode >= http.StatusInternalServerError:
+		e.cause = ErrServerError
+	}
+	return e
+}
+
+func newDecodeError(endpoint string, body []byte, err error) error {
+	excerpt := body
+	if len(excerpt) > excerptLength {
+		excerpt = excerpt[:excerptLength]
+		for len(excerpt) > 0 && !utf8.Valid(excerpt) {
+			excerpt = excerpt[:len(excerpt)-1]
+		}
+	}
+	return &DecodeError{
+		Endpoint: endpoint,
+		Excerpt:  string(excerpt),
+		Err:      err,
+	}
+}
+
+// Reason returns a short label describing
+// the error for metrics
+func Reason(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrUnsupportedAPIVersion):
+		return "unsupported_api_version"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrServerError):
+		return "server_error"
+	case errors.Is(err, ErrDecode):
+		return "decode"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	default:
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			return "http_status"
+		}
+		return "unknown"
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"qbittorrent_exporter/lib/log"
+	"strconv"
+	"strings"
+)
+
+// APIVersion is a qBittorrent Web API version,
+// not to be confused with the application version
+type APIVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// capabilities holds the minimal Web API
+// version each endpoint is available since
+var capabilities = map[string]APIVersion{
+	torrentsInfo:  {2, 0, 0},
+	transferInfo:  {2, 0, 0},
+	appVersion:    {2, 0, 0},
+	webapiVersion: {2, 0, 0},
+	syncMaindata:  {2, 0, 0},
+	categories:    {2, 1, 1},
+	tags:          {2, 3, 0},
+
+	speedLimitsMode: {2, 0, 0},
+	appPreferences:  {2, 0, 0},
+}
+
+func ParseAPIVersion(s string) (APIVersion, error) {
+	var v APIVersion
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, fmt.Errorf("invalid Web API version: %q", s)
+	}
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, fmt.Errorf("invalid Web API version: %q", s)
+		}
+		nums[i] = n
+	}
+	return APIVersion{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+func (v APIVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (v APIVersion) IsZero() bool {
+	return v == APIVersion{}
+}
+
+// AtLeast reports whether v is the same or newer than o
+func (v APIVersion) AtLeast(o APIVersion) bool {
+	if v.Major != o.Major {
+		return v.Major > o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor > o.Minor
+	}
+	return v.Patch >= o.Patch
+}
+
+// WebAPIVersion returns version detected after login,
+// zero value if detection failed
+func (api *QBittorrentAPI) WebAPIVersion() APIVersion {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.webAPIVersion
+}
+
+// Supports reports whether qBittorrent provides the endpoint.
+// Endpoints are assumed supported if version is unknown,
+// until qBittorrent responds with 404 several times in a row
+func (api *QBittorrentAPI) Supports(endpoint string) bool {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.unsupported[endpoint] {
+		return false
+	}
+	since, ok := capabilities[endpoint]
+	if !ok || api.webAPIVersion.IsZero() {
+		return true
+	}
+	return api.webAPIVersion.AtLeast(since)
+}
+
+func (api *QBittorrentAPI) WebAPIVersionContext(ctx context.Context) (APIVersion, error) {
+	body, err := api.doAuthenticatedGet(ctx, webapiVersion, contentTypePlain)
+	if err != nil {
+		return APIVersion{}, err
+	}
+	return ParseAPIVersion(string(body))
+}
+
+func (api *QBittorrentAPI) detectWebAPIVersion(ctx context.Context) error {
+	v, err := api.WebAPIVersionContext(ctx)
+	if err != nil {
+		return err
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.webAPIVersion = v
+	return nil
+}
+
+// checkSupported returns ErrUnsupportedAPIVersion
+// without a request for unsupported endpoints
+func (api *QBittorrentAPI) checkSupported(endpoint string) error {
+	if api.Supports(endpoint) {
+		return nil
+	}
+	return fmt.Errorf("%s: %w %s", endpoint, ErrUnsupportedAPIVersion, api.WebAPIVersion())
+}
+
+// notFoundThreshold is how many consecutive 404 responses
+// mark an endpoint unsupported while the version is unknown
+const notFoundThreshold = 3
+
+// markUnsupported remembers endpoints missing in qBittorrent
+// whose Web API version is unknown, so they are not requested every
+// poll. A single 404 may be transient, so only consecutive ones count.
+// Known versions are handled by checkSupported before the request
+func (api *QBittorrentAPI) markUnsupported(endpoint string, err error) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if !errors.Is(err, ErrNotFound) || !api.webAPIVersion.IsZero() {
+		delete(api.notFound, endpoint)
+		return err
+	}
+	api.notFound[endpoint]++
+	if api.notFound[endpoint] < notFoundThreshold {
+		return err
+	}
+	api.unsupported[endpoint] = true
+	log.Warn(fmt.Sprintf("%s responded 404 %d times in a row, it won't be requested until the next login", endpoint, notFoundThreshold))
+	return fmt.Errorf("%w: %w", ErrUnsupportedAPIVersion, err)
+}
+
+// resetUnsupported forgets endpoints marked unsupported,
+// qBittorrent may have been upgraded when the session is renewed
+func (api *QBittorrentAPI) resetUnsupported() {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	clear(api.unsupported)
+	clear(api.notFound)
+}
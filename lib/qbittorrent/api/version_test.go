@@ -0,0 +1,50 @@
+package api
+
+import "testing"
+
+func TestParseAPIVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    APIVersion
+		wantErr bool
+	}{
+		{"2.11.4", APIVersion{2, 11, 4}, false},
+		{"2.8", APIVersion{2, 8, 0}, false},
+		{" 2.9.3\n", APIVersion{2, 9, 3}, false},
+		{"2", APIVersion{}, true},
+		{"2.8.1.1", APIVersion{}, true},
+		{"v2.8", APIVersion{}, true},
+		{"2..1", APIVersion{}, true},
+		{"", APIVersion{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAPIVersion(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAPIVersion(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAPIVersion(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAPIVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		v, o APIVersion
+		want bool
+	}{
+		{APIVersion{2, 3, 0}, APIVersion{2, 3, 0}, true},
+		{APIVersion{2, 3, 1}, APIVersion{2, 3, 0}, true},
+		{APIVersion{2, 2, 9}, APIVersion{2, 3, 0}, false},
+		{APIVersion{2, 10, 0}, APIVersion{2, 9, 9}, true},
+		{APIVersion{3, 0, 0}, APIVersion{2, 11, 0}, true},
+		{APIVersion{1, 99, 99}, APIVersion{2, 0, 0}, false},
+		{APIVersion{}, APIVersion{2, 0, 0}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.v.AtLeast(tt.o); got != tt.want {
+			t.Errorf("%v.AtLeast(%v) = %v, want %v", tt.v, tt.o, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package scheduler
 
 import (
+	"context"
+	"math"
+	"math/rand/v2"
 	"qbittorrent_exporter/lib/log"
 	"sync"
 	"time"
@@ -13,27 +16,65 @@ var (
 
 type (
 	Scheduler struct {
-		wg *sync.WaitGroup
+		wg     *sync.WaitGroup
+		ctx    context.Context
+		cancel context.CancelFunc
+		mu     sync.Mutex
+		tasks  map[string]*task
+		order  []string
 	}
 
 	PeriodicTaskOpts struct {
 		Interval time.Duration
 		IsFast   bool
+		// Timeout is a deadline of every attempt to run the task
+		Timeout time.Duration
+		// StartJitter delays the first run by a random
+		// duration in [0, StartJitter) to spread tasks
+		StartJitter time.Duration
+		Retry       *RetryPolicy
+		Breaker     *BreakerPolicy
+		// OnBackoff is called after every run with the delay
+		// added on top of Interval by the circuit-breaker
+		OnBackoff func(time.Duration)
 	}
 
-	taskFunc func() error
+	// RetryPolicy controls retries of a failed run
+	// within a single interval
+	RetryPolicy struct {
+		MaxAttempts    int
+		InitialBackoff time.Duration
+		MaxBackoff     time.Duration
+		// Jitter is a fraction [0, 1] of the backoff
+		// which is randomly added or subtracted
+		Jitter float64
+	}
+
+	// BreakerPolicy slows polling down after Threshold
+	// consecutive failed runs, doubling the interval
+	// on every further failure up to MaxInterval
+	BreakerPolicy struct {
+		Threshold   int
+		MaxInterval time.Duration
+	}
+
+	// taskFunc must return when ctx is done
+	taskFunc func(ctx context.Context) error
 )
 
-func Run(task taskFunc, po *PeriodicTaskOpts) {
+// Run starts the named task in background,
+// nil po runs the task once
+func Run(name string, fn taskFunc, po *PeriodicTaskOpts) {
 	scheduler := Get()
+	t := scheduler.register(name, fn, po)
 	scheduler.wg.Add(1)
 
 	go func() {
 		defer scheduler.wg.Done()
 		if po != nil {
-			scheduler.RunPeriodicTask(task, po)
-		} else if err := task(); err != nil {
-			log.Error(err.Error())
+			scheduler.runPeriodicTask(t, po)
+		} else if err := scheduler.execute(t, nil); err != nil && scheduler.ctx.Err() == nil {
+			log.Error(err.Error(), "task", name)
 		}
 	}()
 }
@@ -44,8 +85,12 @@ func Get() *Scheduler {
 			lock.Lock()
 			defer lock.Unlock()
 			var wg sync.WaitGroup
+			ctx, cancel := context.WithCancel(context.Background())
 			singleInstance = &Scheduler{
-				wg: &wg,
+				wg:     &wg,
+				ctx:    ctx,
+				cancel: cancel,
+				tasks:  map[string]*task{},
 			}
 		}()
 	}
@@ -56,26 +101,154 @@ func (s *Scheduler) Wait() {
 	s.wg.Wait()
 }
 
-func (s *Scheduler) RunPeriodicTask(task taskFunc, o *PeriodicTaskOpts) {
+// Shutdown cancels context of running tasks,
+// use Wait to wait until they return
+func (s *Scheduler) Shutdown() {
+	s.cancel()
+}
+
+// Context is cancelled on Shutdown
+func (s *Scheduler) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Scheduler) runPeriodicTask(t *task, o *PeriodicTaskOpts) {
 	opts := o
 	if o == nil {
-		log.Warn("PeriodicTaskOpts is nil, using default values")
+		log.Warn("PeriodicTaskOpts is nil, using default values", "task", t.name)
 		opts = &PeriodicTaskOpts{
 			Interval: 30 * time.Second,
 			IsFast:   false,
 		}
 	}
-	ticker := time.NewTicker(opts.Interval)
-	defer ticker.Stop()
 
-	if opts.IsFast {
-		if err := task(); err != nil {
-			log.Error(err.Error())
+	var delay time.Duration
+	if opts.StartJitter > 0 {
+		delay += rand.N(opts.StartJitter)
+	}
+	if !opts.IsFast {
+		delay += opts.Interval
+	}
+	if !s.sleepUntilNextRun(t, delay) {
+		return
+	}
+
+	for {
+		var failures int
+		err := s.execute(t, opts)
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error(err.Error(), "task", t.name)
+		}
+		s.update(t, func(ts *TaskStatus) {
+			failures = ts.ConsecutiveFailures
+		})
+
+		backoff := opts.Breaker.backoff(failures, opts.Interval)
+		s.update(t, func(ts *TaskStatus) {
+			ts.Backoff = backoff
+		})
+		if opts.OnBackoff != nil {
+			opts.OnBackoff(backoff)
+		}
+		if backoff > 0 {
+			log.Warn("Task keeps failing, slowing down polling", "task", t.name, "failures", failures, "backoff", backoff)
+		}
+		if !s.sleepUntilNextRun(t, opts.Interval+backoff) {
+			return
+		}
+	}
+}
+
+// sleepUntilNextRun returns false if
+// the scheduler was shut down meanwhile
+func (s *Scheduler) sleepUntilNextRun(t *task, d time.Duration) bool {
+	s.update(t, func(ts *TaskStatus) {
+		ts.NextRun = time.Now().Add(d)
+	})
+	return sleep(s.ctx, d)
+}
+
+func runWithRetry(ctx context.Context, name string, fn taskFunc, opts *PeriodicTaskOpts) error {
+	if opts == nil {
+		return fn(ctx)
+	}
+
+	err := runAttempt(ctx, fn, opts.Timeout)
+	rp := opts.Retry
+	if rp == nil {
+		return err
+	}
+	for attempt := 1; err != nil && attempt < rp.MaxAttempts; attempt++ {
+		delay := rp.delay(attempt)
+		log.Warn("Task failed, retrying", "task", name, "attempt", attempt, "delay", delay, "error", err.Error())
+		if !sleep(ctx, delay) {
+			return ctx.Err()
 		}
+		err = runAttempt(ctx, fn, opts.Timeout)
+	}
+	return err
+}
+
+func runAttempt(ctx context.Context, fn taskFunc, timeout time.Duration) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// sleep returns false if ctx is done before d elapses
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
-	for range ticker.C {
-		if err := task(); err != nil {
-			log.Error(err.Error())
+}
+
+// delay returns exponential backoff with jitter
+// before the next attempt, attempt starts from 1
+func (rp *RetryPolicy) delay(attempt int) time.Duration {
+	d := exponential(rp.InitialBackoff, attempt-1, rp.MaxBackoff)
+	if rp.Jitter > 0 && d > 0 {
+		spread := float64(d) * min(rp.Jitter, 1)
+		d += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+// backoff returns the delay added on top of interval
+// after the given number of consecutive failed runs
+func (bp *BreakerPolicy) backoff(failures int, interval time.Duration) time.Duration {
+	if bp == nil || bp.Threshold <= 0 || failures < bp.Threshold {
+		return 0
+	}
+	slowed := exponential(interval, failures-bp.Threshold+1, bp.MaxInterval)
+	return max(slowed-interval, 0)
+}
+
+// exponential returns base*2^n capped by limit,
+// non-positive limit means no cap
+func exponential(base time.Duration, n int, limit time.Duration) time.Duration {
+	d := base
+	for range n {
+		if d > math.MaxInt64/2 {
+			return time.Duration(math.MaxInt64)
 		}
+		d *= 2
+		if limit > 0 && d >= limit {
+			return limit
+		}
+	}
+	if limit > 0 && d > limit {
+		return limit
 	}
+	return d
 }
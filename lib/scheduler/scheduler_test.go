@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestExponential(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  time.Duration
+		n     int
+		limit time.Duration
+		want  time.Duration
+	}{
+		{"zero exponent", time.Second, 0, time.Minute, time.Second},
+		{"doubles", time.Second, 3, time.Minute, 8 * time.Second},
+		{"capped", time.Second, 10, time.Minute, time.Minute},
+		{"base above limit", 2 * time.Minute, 0, time.Minute, time.Minute},
+		{"no cap", time.Second, 10, 0, 1024 * time.Second},
+		{"negative limit is no cap", time.Second, 2, -time.Second, 4 * time.Second},
+		{"saturates without cap", time.Second, 100, 0, time.Duration(math.MaxInt64)},
+		{"capped before overflow", time.Second, 100, time.Hour, time.Hour},
+		{"zero base", 0, 10, time.Minute, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exponential(tt.base, tt.n, tt.limit); got != tt.want {
+				t.Errorf("exponential(%v, %d, %v) = %v, want %v", tt.base, tt.n, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	rp := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{1000, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := rp.delay(tt.attempt); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayJitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		jitter   float64
+		min, max time.Duration
+	}{
+		{"fraction", 0.2, 8 * time.Second, 12 * time.Second},
+		{"above one is clamped", 5, 0, 20 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp := &RetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: time.Minute, Jitter: tt.jitter}
+			for range 100 {
+				if got := rp.delay(1); got < tt.min || got > tt.max {
+					t.Fatalf("delay(1) = %v, want within [%v, %v]", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerPolicyBackoff(t *testing.T) {
+	interval := 30 * time.Second
+	tests := []struct {
+		name     string
+		policy   *BreakerPolicy
+		failures int
+		want     time.Duration
+	}{
+		{"nil policy", nil, 10, 0},
+		{"disabled", &BreakerPolicy{Threshold: 0, MaxInterval: 5 * time.Minute}, 10, 0},
+		{"below threshold", &BreakerPolicy{Threshold: 3, MaxInterval: 5 * time.Minute}, 2, 0},
+		{"at threshold", &BreakerPolicy{Threshold: 3, MaxInterval: 5 * time.Minute}, 3, 30 * time.Second},
+		{"doubles", &BreakerPolicy{Threshold: 3, MaxInterval: 5 * time.Minute}, 5, 210 * time.Second},
+		{"capped", &BreakerPolicy{Threshold: 3, MaxInterval: 5 * time.Minute}, 6, 270 * time.Second},
+		{"capped after many failures", &BreakerPolicy{Threshold: 3, MaxInterval: 5 * time.Minute}, 10000, 270 * time.Second},
+		{"max interval below interval", &BreakerPolicy{Threshold: 1, MaxInterval: 10 * time.Second}, 5, 0},
+		{"saturates without cap", &BreakerPolicy{Threshold: 1, MaxInterval: 0}, 100, time.Duration(math.MaxInt64) - interval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.backoff(tt.failures, interval); got != tt.want {
+				t.Errorf("backoff(%d, %v) = %v, want %v", tt.failures, interval, got, tt.want)
+			}
+		})
+	}
+}
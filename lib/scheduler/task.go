@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// TaskStatus is a snapshot of a task's bookkeeping
+type TaskStatus struct {
+	Name                string
+	Periodic            bool
+	Interval            time.Duration
+	Running             bool
+	Runs                int
+	LastRun             time.Time
+	LastDuration        time.Duration
+	LastSuccess         time.Time
+	LastError           string
+	ConsecutiveFailures int
+	Backoff             time.Duration
+	NextRun             time.Time
+}
+
+type task struct {
+	name   string
+	fn     taskFunc
+	status TaskStatus
+}
+
+// register adds a task into the registry,
+// a task with the same name is replaced
+func (s *Scheduler) register(name string, fn taskFunc, po *PeriodicTaskOpts) *task {
+	t := &task{
+		name: name,
+		fn:   fn,
+		status: TaskStatus{
+			Name:     name,
+			Periodic: po != nil,
+		},
+	}
+	if po != nil {
+		t.status.Interval = po.Interval
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[name]; !ok {
+		s.order = append(s.order, name)
+	}
+	s.tasks[name] = t
+	return t
+}
+
+// Tasks returns status of all registered
+// tasks in order of registration
+func (s *Scheduler) Tasks() []TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]TaskStatus, 0, len(s.order))
+	for _, name := range s.order {
+		statuses = append(statuses, s.tasks[name].status)
+	}
+	return statuses
+}
+
+// Task returns status of the named task
+func (s *Scheduler) Task(name string) (TaskStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[name]
+	if !ok {
+		return TaskStatus{}, false
+	}
+	return t.status, true
+}
+
+func (s *Scheduler) update(t *task, fn func(*TaskStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&t.status)
+}
+
+// execute runs the task once, recording its outcome,
+// nil opts run the task without retries and timeout
+func (s *Scheduler) execute(t *task, opts *PeriodicTaskOpts) error {
+	start := time.Now()
+	s.update(t, func(ts *TaskStatus) {
+		ts.Running = true
+		ts.LastRun = start
+	})
+
+	err := runWithRetry(s.ctx, t.name, t.fn, opts)
+
+	s.update(t, func(ts *TaskStatus) {
+		ts.Running = false
+		ts.Runs++
+		ts.LastDuration = time.Since(start)
+		if err != nil {
+			ts.LastError = err.Error()
+			ts.ConsecutiveFailures++
+		} else {
+			ts.LastError = ""
+			ts.LastSuccess = time.Now()
+			ts.ConsecutiveFailures = 0
+		}
+	})
+	return err
+}
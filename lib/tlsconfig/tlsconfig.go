@@ -0,0 +1,206 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"qbittorrent_exporter/lib/log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var versions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+type ClientOpts struct {
+	InsecureSkipVerify bool
+	// CAFile is a PEM bundle used instead of system roots
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// Host is qBittorrent's host, ServerName applies only to
+	// its connections and not to the handshake with an HTTPS proxy
+	Host string
+	// ServerName overrides the hostname the certificate of Host
+	// is verified against
+	ServerName string
+	MinVersion uint16
+}
+
+// ParseVersion accepts TLS10, TLS11, TLS12 or TLS13
+func ParseVersion(name string) (uint16, error) {
+	v, ok := versions[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version: %s", name)
+	}
+	return v, nil
+}
+
+// reloader keeps CA pool and client certificate
+// in sync with files on disk
+type reloader struct {
+	opts *ClientOpts
+
+	mu       sync.Mutex
+	caStamp  fileStamp
+	pool     *x509.CertPool
+	crtStamp fileStamp
+	keyStamp fileStamp
+	cert     *tls.Certificate
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewClientConfig returns TLS config which re-reads CA and client
+// certificate files on handshake when they change on disk
+func NewClientConfig(o *ClientOpts) (*tls.Config, error) {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, fmt.Errorf("both certFile and keyFile must be set")
+	}
+
+	// ServerName is left empty so net/http sets it
+	// to the host of each connection, proxy or qBittorrent
+	cfg := &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		MinVersion:         o.MinVersion,
+	}
+	r := &reloader{opts: o}
+
+	if o.CertFile != "" {
+		if _, err := r.certificate(); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.certificate()
+		}
+	}
+
+	if o.CAFile != "" && !o.InsecureSkipVerify {
+		if _, err := r.caPool(); err != nil {
+			return nil, err
+		}
+	}
+	// default verification can't swap roots after the config is
+	// created nor verify one host against another name, so the
+	// chain is verified in VerifyConnection
+	if (o.CAFile != "" || o.ServerName != "") && !o.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = r.verifyConnection
+	}
+	return cfg, nil
+}
+
+func (r *reloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	crtStamp, err := stat(r.opts.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	keyStamp, err := stat(r.opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if r.cert != nil && crtStamp == r.crtStamp && keyStamp == r.keyStamp {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client certificate: %w", err)
+	}
+	if r.cert != nil {
+		log.Info("Reloaded client certificate from " + r.opts.CertFile)
+	}
+	r.cert, r.crtStamp, r.keyStamp = &cert, crtStamp, keyStamp
+	return r.cert, nil
+}
+
+func (r *reloader) caPool() (*x509.CertPool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp, err := stat(r.opts.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if r.pool != nil && stamp == r.caStamp {
+		return r.pool, nil
+	}
+
+	pem, err := os.ReadFile(r.opts.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", r.opts.CAFile)
+	}
+	if r.pool != nil {
+		log.Info("Reloaded CA bundle from " + r.opts.CAFile)
+	}
+	r.pool, r.caStamp = pool, stamp
+	return r.pool, nil
+}
+
+func (r *reloader) verifyConnection(cs tls.ConnectionState) error {
+	// nil pool verifies against system roots
+	var pool *x509.CertPool
+	if r.opts.CAFile != "" {
+		var err error
+		if pool, err = r.caPool(); err != nil {
+			return err
+		}
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server presented no certificates")
+	}
+
+	serverName := r.serverName(cs.ServerName)
+	if serverName == "" {
+		return fmt.Errorf("server name is required to verify the certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// serverName returns the name to verify the certificate of
+// the connection against. sni is the host of the connection,
+// empty for IP addresses which crypto/tls leaves out of SNI;
+// DNSName of the verification matches both hostnames and IP SANs
+func (r *reloader) serverName(sni string) string {
+	if sni != "" && sni != r.opts.Host {
+		return sni
+	}
+	if r.opts.ServerName != "" {
+		return r.opts.ServerName
+	}
+	return r.opts.Host
+}
+
+func stat(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
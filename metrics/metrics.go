@@ -2,11 +2,20 @@ package metrics
 
 import (
 	"fmt"
+	"qbittorrent_exporter/config"
+	"qbittorrent_exporter/feature"
+	"qbittorrent_exporter/forecast"
+	"qbittorrent_exporter/goals"
+	"qbittorrent_exporter/inventory"
 	"qbittorrent_exporter/lib/log"
+	"qbittorrent_exporter/problems"
+	"qbittorrent_exporter/rolling"
 	"qbittorrent_exporter/state"
 	"qbittorrent_exporter/types"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -15,55 +24,164 @@ var (
 	lock                  = &sync.Mutex{}
 	metricsPrefix  string = "qb_"
 	singleInstance *Metrics
+	// torrentOptional toggles optional torrent metrics
+	torrentOptional config.TorrentMetricsConfig
 )
 
 type Metrics struct {
-	torrent  *torrentMetrics
-	transfer *transferMetrics
-	version  *versionMetrics
+	torrent   *torrentMetrics
+	transfer  *transferMetrics
+	version   *versionMetrics
+	scheduler *schedulerMetrics
+	problems  *problemMetrics
+	goals     *goalMetrics
+	rolling   *rollingMetrics
+	disk      *diskMetrics
+	inventory *inventoryMetrics
+	totals    *totalsCollector
+
+	// torrentNames are names of torrents exported by the last poll,
+	// torrentHashes are their hashes
+	torrentNames  map[string]bool
+	torrentHashes map[string]bool
+
+	// label sets exported by the last update of vectors
+	// fully replaced on every update
+	problemSeries      *labelSets
+	goalSeries         *labelSets
+	goalTrackerSeries  *labelSets
+	rollingSeries      *labelSets
+	remainingSeries    *labelSets
+	categoryInfoSeries *labelSets
+	categorySeries     *labelSets
+	tagSeries          *labelSets
 }
 
 type torrentMetrics struct {
-	Name       *prometheus.GaugeVec
-	State      *prometheus.GaugeVec
-	Progress   *prometheus.GaugeVec
-	DlSpeed    *prometheus.GaugeVec
-	UpSpeed    *prometheus.GaugeVec
-	Downloaded *prometheus.GaugeVec
-	AmountLeft *prometheus.GaugeVec
-	Ratio      *prometheus.GaugeVec
-	Eta        *prometheus.GaugeVec
-	NumSeeds   *prometheus.GaugeVec
-	NumLeechs  *prometheus.GaugeVec
+	Name       *gaugeVec
+	State      *gaugeVec
+	StateSince *gaugeVec
+	Progress   *gaugeVec
+	DlSpeed    *gaugeVec
+	UpSpeed    *gaugeVec
+	Downloaded *gaugeVec
+	AmountLeft *gaugeVec
+	Ratio      *gaugeVec
+	Eta        *gaugeVec
+	NumSeeds   *gaugeVec
+	NumLeechs  *gaugeVec
+
+	Size              *gaugeVec
+	TotalSize         *gaugeVec
+	UploadedSession   *gaugeVec
+	DownloadedSession *gaugeVec
+	Availability      *gaugeVec
+	SeedingTime       *gaugeVec
+	TimeActive        *gaugeVec
+	AddedOn           *gaugeVec
+	CompletionOn      *gaugeVec
+	LastActivity      *gaugeVec
+	NumComplete       *gaugeVec
+	NumIncomplete     *gaugeVec
+	DlLimit           *gaugeVec
+	UpLimit           *gaugeVec
+	Priority          *gaugeVec
+	MaxRatio          *gaugeVec
+	SeedingTimeLimit  *gaugeVec
 }
 
 type transferMetrics struct {
-	Status          *prometheus.GaugeVec
-	DlInfoSpeed     *prometheus.GaugeVec
-	DlInfoData      *prometheus.GaugeVec
-	UpInfoSpeed     *prometheus.GaugeVec
-	UpInfoData      *prometheus.GaugeVec
-	DlRateLimit     *prometheus.GaugeVec
-	UpRateLimit     *prometheus.GaugeVec
-	DhtNodes        *prometheus.GaugeVec
-	DlInfoDataTotal *prometheus.GaugeVec
-	UpInfoDataTotal *prometheus.GaugeVec
+	Status          *gaugeVec
+	DlInfoSpeed     *gaugeVec
+	DlInfoData      *gaugeVec
+	UpInfoSpeed     *gaugeVec
+	UpInfoData      *gaugeVec
+	DlRateLimit     *gaugeVec
+	UpRateLimit     *gaugeVec
+	DhtNodes        *gaugeVec
+	DlInfoDataTotal *gaugeVec
+	UpInfoDataTotal *gaugeVec
+
+	AltSpeedLimits   *gaugeVec
+	AltSpeedSchedule *gaugeVec
+	DlSpeedLimit     *gaugeVec
+	UpSpeedLimit     *gaugeVec
 }
 
 type versionMetrics struct {
-	Version *prometheus.GaugeVec
+	Version       *gaugeVec
+	WebAPIVersion *gaugeVec
+}
+
+type problemMetrics struct {
+	Problem  *gaugeVec
+	Problems *gaugeVec
+}
+
+type goalMetrics struct {
+	Progress *gaugeVec
+	Met      *gaugeVec
+	ETA      *gaugeVec
+	AtRisk   *gaugeVec
+}
+
+type rollingMetrics struct {
+	DownloadRate *gaugeVec
+	UploadRate   *gaugeVec
+	Downloaded   *gaugeVec
+	Uploaded     *gaugeVec
+}
+
+type diskMetrics struct {
+	FreeSpace    *gaugeVec
+	Remaining    *gaugeVec
+	ForecastFree *gaugeVec
+	FullETA      *gaugeVec
+	QueueETA     *gaugeVec
+}
+
+type inventoryMetrics struct {
+	CategoryInfo     *gaugeVec
+	CategoryTorrents *gaugeVec
+	CategorySize     *gaugeVec
+	CategoryDlSpeed  *gaugeVec
+	CategoryUpSpeed  *gaugeVec
+	TagTorrents      *gaugeVec
+	TagSize          *gaugeVec
+	TagDlSpeed       *gaugeVec
+	TagUpSpeed       *gaugeVec
+	Uncategorized    *gaugeVec
+	Untagged         *gaugeVec
+}
+
+type schedulerMetrics struct {
+	TaskBackoff *gaugeVec
+	TaskErrors  *prometheus.CounterVec
 }
 
 func UpdatePrefix(prefix string) {
 	metricsPrefix = prefix
 }
 
+func UpdateTorrentMetrics(cfg config.TorrentMetricsConfig) {
+	torrentOptional = cfg
+}
+
 func Get() *Metrics {
 	if singleInstance == nil {
 		lock.Lock()
 		defer lock.Unlock()
 
-		singleInstance = &Metrics{}
+		singleInstance = &Metrics{
+			problemSeries:      newLabelSets(),
+			goalSeries:         newLabelSets(),
+			goalTrackerSeries:  newLabelSets(),
+			rollingSeries:      newLabelSets(),
+			remainingSeries:    newLabelSets(),
+			categoryInfoSeries: newLabelSets(),
+			categorySeries:     newLabelSets(),
+			tagSeries:          newLabelSets(),
+		}
 		singleInstance.initialize()
 	}
 	return singleInstance
@@ -71,141 +189,502 @@ func Get() *Metrics {
 
 func (m *Metrics) initialize() {
 	m.torrent = &torrentMetrics{
-		Name: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_name",
-			Help: "Name of the torrent",
+		Name: newGaugeVec(metricOpts{
+			Name:       "torrent_info",
+			LegacyName: "torrent_name",
+			Help:       "Name of the torrent",
+		}, []string{"name"}),
+
+		State: newGaugeVec(metricOpts{
+			Name:       "torrent_state",
+			LegacyName: "torrent_state",
+			Help:       "State of the torrent, 1 for the current state and 0 for other known states",
+		}, []string{"hash", "name", "state"}),
+
+		Progress: newGaugeVec(metricOpts{
+			Name:       "torrent_progress_ratio",
+			LegacyName: "torrent_progress",
+			Help:       "Progress of the torrent",
+		}, []string{"name"}),
+
+		DlSpeed: newGaugeVec(metricOpts{
+			Name:       "torrent_download_speed_bytes_per_second",
+			LegacyName: "torrent_dlspeed",
+			Help:       "Download speed of the torrent",
+		}, []string{"name"}),
+
+		UpSpeed: newGaugeVec(metricOpts{
+			Name:       "torrent_upload_speed_bytes_per_second",
+			LegacyName: "torrent_upspeed",
+			Help:       "Upload speed of the torrent",
+		}, []string{"name"}),
+
+		Downloaded: newGaugeVec(metricOpts{
+			LegacyName: "torrent_downloaded",
+			Help:       "Amount of data downloaded",
+		}, []string{"name"}),
+
+		AmountLeft: newGaugeVec(metricOpts{
+			Name:       "torrent_remaining_bytes",
+			LegacyName: "torrent_amount_left",
+			Help:       "Amount of data left to download",
+		}, []string{"name"}),
+
+		Ratio: newGaugeVec(metricOpts{
+			Name:       "torrent_share_ratio",
+			LegacyName: "torrent_ratio",
+			Help:       "Torrent share ratio",
+		}, []string{"name"}),
+
+		Eta: newGaugeVec(metricOpts{
+			Name:       "torrent_eta_seconds",
+			LegacyName: "torrent_eta",
+			Help:       "Estimated time to completion",
+		}, []string{"name"}),
+
+		NumSeeds: newGaugeVec(metricOpts{
+			Name:       "torrent_connected_seeds",
+			LegacyName: "torrent_num_seeds",
+			Help:       "Number of seeds connected to",
+		}, []string{"name"}),
+
+		NumLeechs: newGaugeVec(metricOpts{
+			Name:       "torrent_connected_leechers",
+			LegacyName: "torrent_num_leechs",
+			Help:       "Number of leechers connected to",
+		}, []string{"name"}),
+
+		StateSince: newGaugeVec(metricOpts{
+			Name: "torrent_state_since_timestamp_seconds",
+			Help: "Time the torrent entered its current state, or the exporter first saw it",
+		}, []string{"hash", "name"}),
+
+		Size: newGaugeVec(metricOpts{
+			Name: "torrent_size_bytes",
+			Help: "Size of the selected files",
+		}, []string{"name"}),
+
+		TotalSize: newGaugeVec(metricOpts{
+			Name: "torrent_total_size_bytes",
+			Help: "Size of all files, including unselected ones",
+		}, []string{"name"}),
+
+		UploadedSession: newGaugeVec(metricOpts{
+			Name: "torrent_session_uploaded_bytes",
+			Help: "Data uploaded this session",
+		}, []string{"name"}),
+
+		DownloadedSession: newGaugeVec(metricOpts{
+			Name: "torrent_session_downloaded_bytes",
+			Help: "Data downloaded this session",
 		}, []string{"name"}),
 
-		State: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_state",
-			Help: "State of the torrent",
-		}, []string{"name", "state"}),
+		Availability: newGaugeVec(metricOpts{
+			Name: "torrent_availability",
+			Help: "Distributed copies of the torrent available to the client",
+		}, []string{"name"}),
+
+		SeedingTime: newGaugeVec(metricOpts{
+			Name: "torrent_seeding_time_seconds",
+			Help: "Time the torrent has been seeding",
+		}, []string{"name"}),
 
-		Progress: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_progress",
-			Help: "Progress of the torrent",
+		TimeActive: newGaugeVec(metricOpts{
+			Name: "torrent_active_time_seconds",
+			Help: "Time the torrent has been active",
 		}, []string{"name"}),
 
-		DlSpeed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_dlspeed",
-			Help: "Download speed of the torrent",
+		AddedOn: newGaugeVec(metricOpts{
+			Name: "torrent_added_timestamp_seconds",
+			Help: "Time the torrent was added",
 		}, []string{"name"}),
 
-		UpSpeed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_upspeed",
-			Help: "Upload speed of the torrent",
+		CompletionOn: newGaugeVec(metricOpts{
+			Name: "torrent_completion_timestamp_seconds",
+			Help: "Time the torrent was completed",
 		}, []string{"name"}),
 
-		Downloaded: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_downloaded",
-			Help: "Amount of data downloaded",
+		LastActivity: newGaugeVec(metricOpts{
+			Name: "torrent_last_activity_timestamp_seconds",
+			Help: "Time a chunk was last downloaded or uploaded",
 		}, []string{"name"}),
 
-		AmountLeft: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_amount_left",
-			Help: "Amount of data left to download",
+		NumComplete: newGaugeVec(metricOpts{
+			Name: "torrent_swarm_seeds",
+			Help: "Seeds in the swarm",
 		}, []string{"name"}),
 
-		Ratio: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_ratio",
-			Help: "Torrent share ratio",
+		NumIncomplete: newGaugeVec(metricOpts{
+			Name: "torrent_swarm_leechers",
+			Help: "Leechers in the swarm",
 		}, []string{"name"}),
 
-		Eta: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_eta",
-			Help: "Estimated time to completion",
+		DlLimit: newGaugeVec(metricOpts{
+			Name: "torrent_download_limit_bytes_per_second",
+			Help: "Download limit of the torrent, 0 or less if unlimited",
 		}, []string{"name"}),
 
-		NumSeeds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_num_seeds",
-			Help: "Number of seeds connected to",
+		UpLimit: newGaugeVec(metricOpts{
+			Name: "torrent_upload_limit_bytes_per_second",
+			Help: "Upload limit of the torrent, 0 or less if unlimited",
 		}, []string{"name"}),
 
-		NumLeechs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "torrent_num_leechs",
-			Help: "Number of leechers connected to",
+		Priority: newGaugeVec(metricOpts{
+			Name: "torrent_queue_position",
+			Help: "Position in the queue, 0 or less if not queued",
+		}, []string{"name"}),
+
+		MaxRatio: newGaugeVec(metricOpts{
+			Name: "torrent_max_share_ratio",
+			Help: "Share ratio limit, negative if unlimited",
+		}, []string{"name"}),
+
+		SeedingTimeLimit: newGaugeVec(metricOpts{
+			Name: "torrent_seeding_time_limit_seconds",
+			Help: "Seeding time limit, negative if unlimited or global limit applies",
 		}, []string{"name"}),
 	}
 
 	m.transfer = &transferMetrics{
-		Status: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_connection_status",
-			Help: "Connection status",
+		Status: newGaugeVec(metricOpts{
+			Name:       "transfer_connected",
+			LegacyName: "transfer_connection_status",
+			Help:       "Connection status",
+		}, []string{}),
+
+		DlInfoSpeed: newGaugeVec(metricOpts{
+			Name:       "transfer_download_speed_bytes_per_second",
+			LegacyName: "transfer_dl_info_speed",
+			Help:       "Global download rate (bytes/s)",
+		}, []string{}),
+
+		DlInfoData: newGaugeVec(metricOpts{
+			Name:       "transfer_session_downloaded_bytes",
+			LegacyName: "transfer_dl_info_data",
+			Help:       "Data downloaded this session (bytes)",
 		}, []string{}),
 
-		DlInfoSpeed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_dl_info_speed",
-			Help: "Global download rate (bytes/s)",
+		UpInfoSpeed: newGaugeVec(metricOpts{
+			Name:       "transfer_upload_speed_bytes_per_second",
+			LegacyName: "transfer_up_info_speed",
+			Help:       "Global upload rate (bytes/s)",
 		}, []string{}),
 
-		DlInfoData: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_dl_info_data",
-			Help: "Data downloaded this session (bytes)",
+		UpInfoData: newGaugeVec(metricOpts{
+			Name:       "transfer_session_uploaded_bytes",
+			LegacyName: "transfer_up_info_data",
+			Help:       "Data uploaded this session (bytes)",
 		}, []string{}),
 
-		UpInfoSpeed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_up_info_speed",
-			Help: "Global upload rate (bytes/s)",
+		DlRateLimit: newGaugeVec(metricOpts{
+			Name:       "transfer_download_rate_limit_bytes_per_second",
+			LegacyName: "transfer_dl_rate_limit",
+			Help:       "Download rate limit (bytes/s)",
 		}, []string{}),
 
-		UpInfoData: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_up_info_data",
-			Help: "Data uploaded this session (bytes)",
+		UpRateLimit: newGaugeVec(metricOpts{
+			Name:       "transfer_upload_rate_limit_bytes_per_second",
+			LegacyName: "transfer_up_rate_limit",
+			Help:       "Upload rate limit (bytes/s)",
 		}, []string{}),
 
-		DlRateLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_dl_rate_limit",
-			Help: "Download rate limit (bytes/s)",
+		DhtNodes: newGaugeVec(metricOpts{
+			Name:       "transfer_dht_nodes",
+			LegacyName: "transfer_dht_nodes",
+			Help:       "DHT nodes connected to",
 		}, []string{}),
 
-		UpRateLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_up_rate_limit",
-			Help: "Upload rate limit (bytes/s)",
+		DlInfoDataTotal: newGaugeVec(metricOpts{
+			LegacyName: "transfer_dl_info_data_total",
+			Help:       "Data downloaded total (bytes)",
 		}, []string{}),
 
-		DhtNodes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_dht_nodes",
-			Help: "DHT nodes connected to",
+		UpInfoDataTotal: newGaugeVec(metricOpts{
+			LegacyName: "transfer_up_info_data_total",
+			Help:       "Data uploaded total (bytes)",
 		}, []string{}),
 
-		DlInfoDataTotal: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_dl_info_data_total",
-			Help: "Data downloaded total (bytes)",
+		AltSpeedLimits: newGaugeVec(metricOpts{
+			Name: "transfer_alt_speed_limits_enabled",
+			Help: "Whether alternative speed limits are active",
 		}, []string{}),
 
-		UpInfoDataTotal: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "transfer_up_info_data_total",
-			Help: "Data downloaded total (bytes)",
+		AltSpeedSchedule: newGaugeVec(metricOpts{
+			Name: "transfer_alt_speed_scheduler_enabled",
+			Help: "Whether alternative speed limits are switched on schedule",
 		}, []string{}),
+
+		DlSpeedLimit: newGaugeVec(metricOpts{
+			Name: "transfer_configured_download_limit_bytes_per_second",
+			Help: "Configured global download limit by mode, 0 if unlimited",
+		}, []string{"mode"}),
+
+		UpSpeedLimit: newGaugeVec(metricOpts{
+			Name: "transfer_configured_upload_limit_bytes_per_second",
+			Help: "Configured global upload limit by mode, 0 if unlimited",
+		}, []string{"mode"}),
 	}
 
 	m.version = &versionMetrics{
-		Version: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricsPrefix + "app_version",
-			Help: "Application version",
+		Version: newGaugeVec(metricOpts{
+			Name:       "app_version_info",
+			LegacyName: "app_version",
+			Help:       "Application version",
+		}, []string{"version"}),
+
+		WebAPIVersion: newGaugeVec(metricOpts{
+			Name:       "app_webapi_version_info",
+			LegacyName: "app_webapi_version",
+			Help:       "Web API version",
 		}, []string{"version"}),
 	}
 
+	m.scheduler = &schedulerMetrics{
+		TaskBackoff: newGaugeVec(metricOpts{
+			Name: "scheduler_task_backoff_seconds",
+			Help: "Delay added to the task interval while qBittorrent is unreachable",
+		}, []string{"task"}),
+
+		TaskErrors: newCounterVec(metricOpts{
+			Name: "scheduler_task_errors_total",
+			Help: "Failed task attempts by reason",
+		}, []string{"task", "reason"}),
+	}
+
+	m.problems = &problemMetrics{
+		Problem: newGaugeVec(metricOpts{
+			Name: "torrent_problem",
+			Help: "Problem detected for the torrent",
+		}, []string{"hash", "name", "reason"}),
+
+		Problems: newGaugeVec(metricOpts{
+			Name: "torrent_problems",
+			Help: "Number of torrents with the problem",
+		}, []string{"reason"}),
+	}
+
+	m.goals = &goalMetrics{
+		Progress: newGaugeVec(metricOpts{
+			Name: "torrent_seeding_goal_progress_ratio",
+			Help: "Progress of the torrent toward its seeding goal",
+		}, []string{"name"}),
+
+		Met: newGaugeVec(metricOpts{
+			Name: "torrent_seeding_goal_met",
+			Help: "Whether the seeding goal of the torrent is met",
+		}, []string{"name"}),
+
+		ETA: newGaugeVec(metricOpts{
+			Name: "torrent_seeding_goal_eta_seconds",
+			Help: "Estimated time until the seeding goal is met at the current upload speed",
+		}, []string{"name"}),
+
+		AtRisk: newGaugeVec(metricOpts{
+			Name: "tracker_seeding_goals_at_risk",
+			Help: "Number of torrents of the tracker which make no progress toward their unmet seeding goal",
+		}, []string{"tracker"}),
+	}
+
+	m.rolling = &rollingMetrics{
+		DownloadRate: newGaugeVec(metricOpts{
+			Name: "torrent_window_download_rate_bytes_per_second",
+			Help: "Average download rate of the torrent within the window",
+		}, []string{"name", "window"}),
+
+		UploadRate: newGaugeVec(metricOpts{
+			Name: "torrent_window_upload_rate_bytes_per_second",
+			Help: "Average upload rate of the torrent within the window",
+		}, []string{"name", "window"}),
+
+		Downloaded: newGaugeVec(metricOpts{
+			Name: "torrent_window_downloaded_bytes",
+			Help: "Data downloaded by the torrent within the window",
+		}, []string{"name", "window"}),
+
+		Uploaded: newGaugeVec(metricOpts{
+			Name: "torrent_window_uploaded_bytes",
+			Help: "Data uploaded by the torrent within the window",
+		}, []string{"name", "window"}),
+	}
+
+	m.disk = &diskMetrics{
+		FreeSpace: newGaugeVec(metricOpts{
+			Name: "disk_free_bytes",
+			Help: "Free space on the disk of the default save path",
+		}, []string{}),
+
+		Remaining: newGaugeVec(metricOpts{
+			Name: "save_path_remaining_bytes",
+			Help: "Data still to be written by torrents of the download queue",
+		}, []string{"save_path"}),
+
+		ForecastFree: newGaugeVec(metricOpts{
+			Name: "disk_forecast_free_bytes",
+			Help: "Free disk space left after the download queue completes, negative if it doesn't fit",
+		}, []string{}),
+
+		FullETA: newGaugeVec(metricOpts{
+			Name: "disk_full_eta_seconds",
+			Help: "Estimated time until the disk is full at the current download rate",
+		}, []string{}),
+
+		QueueETA: newGaugeVec(metricOpts{
+			Name: "download_queue_eta_seconds",
+			Help: "Estimated time until the download queue completes at the current download rate",
+		}, []string{}),
+	}
+
+	m.inventory = &inventoryMetrics{
+		CategoryInfo: newGaugeVec(metricOpts{
+			Name: "category_info",
+			Help: "Category of qBittorrent, empty save_path is the default one",
+		}, []string{"category", "save_path"}),
+
+		CategoryTorrents: newGaugeVec(metricOpts{
+			Name: "category_torrents",
+			Help: "Number of torrents of the category",
+		}, []string{"category"}),
+
+		CategorySize: newGaugeVec(metricOpts{
+			Name: "category_size_bytes",
+			Help: "Size of torrents of the category",
+		}, []string{"category"}),
+
+		CategoryDlSpeed: newGaugeVec(metricOpts{
+			Name: "category_download_speed_bytes_per_second",
+			Help: "Download speed of torrents of the category",
+		}, []string{"category"}),
+
+		CategoryUpSpeed: newGaugeVec(metricOpts{
+			Name: "category_upload_speed_bytes_per_second",
+			Help: "Upload speed of torrents of the category",
+		}, []string{"category"}),
+
+		TagTorrents: newGaugeVec(metricOpts{
+			Name: "tag_torrents",
+			Help: "Number of torrents of the tag",
+		}, []string{"tag"}),
+
+		TagSize: newGaugeVec(metricOpts{
+			Name: "tag_size_bytes",
+			Help: "Size of torrents of the tag",
+		}, []string{"tag"}),
+
+		TagDlSpeed: newGaugeVec(metricOpts{
+			Name: "tag_download_speed_bytes_per_second",
+			Help: "Download speed of torrents of the tag",
+		}, []string{"tag"}),
+
+		TagUpSpeed: newGaugeVec(metricOpts{
+			Name: "tag_upload_speed_bytes_per_second",
+			Help: "Upload speed of torrents of the tag",
+		}, []string{"tag"}),
+
+		Uncategorized: newGaugeVec(metricOpts{
+			Name: "torrents_uncategorized",
+			Help: "Number of torrents without category",
+		}, []string{}),
+
+		Untagged: newGaugeVec(metricOpts{
+			Name: "torrents_untagged",
+			Help: "Number of torrents without tags",
+		}, []string{}),
+	}
+
+	// gauges replaced by counters of totalsCollector,
+	// kept for compatibility until the next release
+	if !feature.Get(feature.LEGACY_TOTAL_GAUGES) {
+		m.torrent.Downloaded = nil
+		m.transfer.DlInfoDataTotal = nil
+		m.transfer.UpInfoDataTotal = nil
+	}
+	m.disableTorrentMetrics()
+	m.totals = newTotalsCollector(torrentOptional.Uploaded)
+
 	registerMetrics(m.torrent)
 	registerMetrics(m.transfer)
 	registerMetrics(m.version)
+	registerMetrics(m.scheduler)
+	registerMetrics(m.problems)
+	registerMetrics(m.goals)
+	registerMetrics(m.rolling)
+	registerMetrics(m.disk)
+	registerMetrics(m.inventory)
+	prometheus.MustRegister(m.totals)
 }
 
-func (m *Metrics) UpdateTorrent(torrents []types.Torrent) {
+func (m *Metrics) UpdateTorrent(torrents []types.Torrent, states map[string]state.TorrentState) {
+	names := make(map[string]bool, len(torrents))
+	hashes := make(map[string]bool, len(torrents))
+	for _, torrent := range torrents {
+		names[torrent.Name] = true
+		hashes[torrent.Hash] = true
+	}
+	for name := range m.torrentNames {
+		if !names[name] {
+			m.deleteTorrent(prometheus.Labels{"name": name})
+		}
+	}
+	// a removed torrent may share its name with a remaining one
+	for hash := range m.torrentHashes {
+		if !hashes[hash] {
+			m.deleteTorrent(prometheus.Labels{"hash": hash})
+		}
+	}
+	m.torrentNames = names
+	m.torrentHashes = hashes
+
 	tm := m.torrent
 	for _, torrent := range torrents {
 		tm.Name.WithLabelValues(torrent.Name).Set(1)
-		tm.State.WithLabelValues(torrent.Name, torrent.State).Set(1)
+		for _, known := range types.States {
+			var current float64 = 0
+			if known == torrent.State {
+				current = 1
+			}
+			tm.State.WithLabelValues(torrent.Hash, torrent.Name, known).Set(current)
+		}
+		if st, ok := states[torrent.Hash]; ok {
+			tm.StateSince.WithLabelValues(torrent.Hash, torrent.Name).Set(float64(st.Since))
+		}
 		tm.Progress.WithLabelValues(torrent.Name).Set(torrent.Progress)
 		tm.DlSpeed.WithLabelValues(torrent.Name).Set(float64(torrent.Dlspeed))
 		tm.UpSpeed.WithLabelValues(torrent.Name).Set(float64(torrent.Upspeed))
-		tm.Downloaded.WithLabelValues(torrent.Name).Set(float64(torrent.Downloaded))
+		if tm.Downloaded != nil {
+			tm.Downloaded.WithLabelValues(torrent.Name).Set(float64(torrent.Downloaded))
+		}
 		tm.AmountLeft.WithLabelValues(torrent.Name).Set(float64(torrent.AmountLeft))
 		tm.Ratio.WithLabelValues(torrent.Name).Set(float64(torrent.Ratio))
 		tm.Eta.WithLabelValues(torrent.Name).Set(float64(torrent.Eta))
 		tm.NumSeeds.WithLabelValues(torrent.Name).Set(float64(torrent.NumSeeds))
 		tm.NumLeechs.WithLabelValues(torrent.Name).Set(float64(torrent.NumLeechs))
+
+		tm.Size.WithLabelValues(torrent.Name).Set(float64(torrent.Size))
+		tm.TotalSize.WithLabelValues(torrent.Name).Set(float64(torrent.TotalSize))
+		tm.UploadedSession.WithLabelValues(torrent.Name).Set(float64(torrent.UploadedSession))
+		tm.DownloadedSession.WithLabelValues(torrent.Name).Set(float64(torrent.DownloadedSession))
+		tm.Availability.WithLabelValues(torrent.Name).Set(torrent.Availability)
+		tm.SeedingTime.WithLabelValues(torrent.Name).Set(float64(torrent.SeedingTime))
+		tm.TimeActive.WithLabelValues(torrent.Name).Set(float64(torrent.TimeActive))
+		// qBittorrent reports 0 or -1 for events which didn't happen
+		if torrent.AddedOn > 0 {
+			tm.AddedOn.WithLabelValues(torrent.Name).Set(float64(torrent.AddedOn))
+		}
+		if torrent.CompletionOn > 0 {
+			tm.CompletionOn.WithLabelValues(torrent.Name).Set(float64(torrent.CompletionOn))
+		}
+		if torrent.LastActivity > 0 {
+			tm.LastActivity.WithLabelValues(torrent.Name).Set(float64(torrent.LastActivity))
+		}
+		tm.NumComplete.WithLabelValues(torrent.Name).Set(float64(torrent.NumComplete))
+		tm.NumIncomplete.WithLabelValues(torrent.Name).Set(float64(torrent.NumIncomplete))
+		tm.DlLimit.WithLabelValues(torrent.Name).Set(float64(torrent.DlLimit))
+		tm.UpLimit.WithLabelValues(torrent.Name).Set(float64(torrent.UpLimit))
+		tm.Priority.WithLabelValues(torrent.Name).Set(float64(torrent.Priority))
+		tm.MaxRatio.WithLabelValues(torrent.Name).Set(torrent.MaxRatio)
+		tm.SeedingTimeLimit.WithLabelValues(torrent.Name).Set(minutesToSeconds(torrent.SeedingTimeLimit))
 	}
+	m.totals.updateTorrents(torrents)
 }
 
 func (m *Metrics) UpdateTransfer(transfer types.Transfer, state state.TransferInfoState) {
@@ -225,8 +704,29 @@ func (m *Metrics) UpdateTransfer(transfer types.Transfer, state state.TransferIn
 
 	log.Debug(fmt.Sprintf("UpdateTransfer() call; DlInfoDataTotal: %d", state.DlInfoDataTotal))
 	log.Debug(fmt.Sprintf("UpdateTransfer() call; UpInfoDataTotal: %d", state.UpInfoDataTotal))
-	tm.DlInfoDataTotal.WithLabelValues().Set(float64(state.DlInfoDataTotal))
-	tm.UpInfoDataTotal.WithLabelValues().Set(float64(state.UpInfoDataTotal))
+	m.totals.updateTransfer(state)
+	if tm.DlInfoDataTotal != nil {
+		tm.DlInfoDataTotal.WithLabelValues().Set(float64(state.DlInfoDataTotal))
+		tm.UpInfoDataTotal.WithLabelValues().Set(float64(state.UpInfoDataTotal))
+	}
+}
+
+func (m *Metrics) UpdateSpeedLimits(altEnabled bool, preferences types.Preferences) {
+	tm := m.transfer
+	var alt float64 = 0
+	if altEnabled {
+		alt = 1
+	}
+	var schedule float64 = 0
+	if preferences.SchedulerEnabled {
+		schedule = 1
+	}
+	tm.AltSpeedLimits.WithLabelValues().Set(alt)
+	tm.AltSpeedSchedule.WithLabelValues().Set(schedule)
+	tm.DlSpeedLimit.WithLabelValues("normal").Set(float64(preferences.DlLimit))
+	tm.DlSpeedLimit.WithLabelValues("alternative").Set(float64(preferences.AltDlLimit))
+	tm.UpSpeedLimit.WithLabelValues("normal").Set(float64(preferences.UpLimit))
+	tm.UpSpeedLimit.WithLabelValues("alternative").Set(float64(preferences.AltUpLimit))
 }
 
 func (m *Metrics) UpdateVersion(version string) {
@@ -234,6 +734,217 @@ func (m *Metrics) UpdateVersion(version string) {
 	vm.Version.WithLabelValues(version).Set(1)
 }
 
+func (m *Metrics) UpdateWebAPIVersion(version string) {
+	vm := m.version
+	vm.WebAPIVersion.WithLabelValues(version).Set(1)
+}
+
+// UpdateProblems replaces previously detected problems
+func (m *Metrics) UpdateProblems(found []problems.Problem) {
+	pm := m.problems
+	counts := make(map[string]int, len(problems.Reasons))
+	for _, problem := range found {
+		pm.Problem.WithLabelValues(m.problemSeries.add(problem.Hash, problem.Name, problem.Reason)...).Set(1)
+		counts[problem.Reason]++
+	}
+	m.problemSeries.deleteStale(pm.Problem)
+	for _, reason := range problems.Reasons {
+		pm.Problems.WithLabelValues(reason).Set(float64(counts[reason]))
+	}
+}
+
+// UpdateGoals replaces previous statuses of seeding goals
+func (m *Metrics) UpdateGoals(statuses []goals.Status) {
+	gm := m.goals
+	atRisk := map[string]int{}
+	for _, status := range statuses {
+		var met float64 = 0
+		if status.Met {
+			met = 1
+		}
+		lvs := m.goalSeries.add(status.Name)
+		gm.Progress.WithLabelValues(lvs...).Set(status.Progress)
+		gm.Met.WithLabelValues(lvs...).Set(met)
+		gm.ETA.WithLabelValues(lvs...).Set(status.ETA)
+
+		count := atRisk[status.Tracker]
+		if status.AtRisk {
+			count++
+		}
+		atRisk[status.Tracker] = count
+	}
+	for tracker, count := range atRisk {
+		gm.AtRisk.WithLabelValues(m.goalTrackerSeries.add(tracker)...).Set(float64(count))
+	}
+	m.goalSeries.deleteStale(gm.Progress, gm.Met, gm.ETA)
+	m.goalTrackerSeries.deleteStale(gm.AtRisk)
+}
+
+// UpdateRolling replaces previous rolling statistics
+func (m *Metrics) UpdateRolling(rates []rolling.Rates) {
+	rm := m.rolling
+	for _, r := range rates {
+		lvs := m.rollingSeries.add(r.Name, rolling.FormatWindow(r.Window))
+		rm.DownloadRate.WithLabelValues(lvs...).Set(r.DownloadRate)
+		rm.UploadRate.WithLabelValues(lvs...).Set(r.UploadRate)
+		rm.Downloaded.WithLabelValues(lvs...).Set(float64(r.Downloaded))
+		rm.Uploaded.WithLabelValues(lvs...).Set(float64(r.Uploaded))
+	}
+	m.rollingSeries.deleteStale(rm.DownloadRate, rm.UploadRate, rm.Downloaded, rm.Uploaded)
+}
+
+func (m *Metrics) UpdateFreeSpace(bytes int64) {
+	dm := m.disk
+	dm.FreeSpace.WithLabelValues().Set(float64(bytes))
+}
+
+// UpdateForecast replaces the previous forecast
+func (m *Metrics) UpdateForecast(fc forecast.Forecast) {
+	dm := m.disk
+	var remaining int64
+	for savePath, bytes := range fc.Remaining {
+		dm.Remaining.WithLabelValues(m.remainingSeries.add(savePath)...).Set(float64(bytes))
+		remaining += bytes
+	}
+	m.remainingSeries.deleteStale(dm.Remaining)
+	dm.QueueETA.WithLabelValues().Set(fc.QueueETA)
+	if fc.HasFreeSpace {
+		dm.ForecastFree.WithLabelValues().Set(float64(fc.FreeSpace - remaining))
+		dm.FullETA.WithLabelValues().Set(fc.DiskFullETA)
+	}
+}
+
+// UpdateCategories replaces previously known categories
+func (m *Metrics) UpdateCategories(categories map[string]types.Category) {
+	im := m.inventory
+	for name, category := range categories {
+		im.CategoryInfo.WithLabelValues(m.categoryInfoSeries.add(name, category.SavePath)...).Set(1)
+	}
+	m.categoryInfoSeries.deleteStale(im.CategoryInfo)
+}
+
+// UpdateInventory replaces previous category and tag groups
+func (m *Metrics) UpdateInventory(summary inventory.Summary) {
+	im := m.inventory
+	for category, group := range summary.Categories {
+		lvs := m.categorySeries.add(category)
+		im.CategoryTorrents.WithLabelValues(lvs...).Set(float64(group.Torrents))
+		im.CategorySize.WithLabelValues(lvs...).Set(float64(group.Size))
+		im.CategoryDlSpeed.WithLabelValues(lvs...).Set(float64(group.DlSpeed))
+		im.CategoryUpSpeed.WithLabelValues(lvs...).Set(float64(group.UpSpeed))
+	}
+	m.categorySeries.deleteStale(im.CategoryTorrents, im.CategorySize, im.CategoryDlSpeed, im.CategoryUpSpeed)
+
+	for tag, group := range summary.Tags {
+		lvs := m.tagSeries.add(tag)
+		im.TagTorrents.WithLabelValues(lvs...).Set(float64(group.Torrents))
+		im.TagSize.WithLabelValues(lvs...).Set(float64(group.Size))
+		im.TagDlSpeed.WithLabelValues(lvs...).Set(float64(group.DlSpeed))
+		im.TagUpSpeed.WithLabelValues(lvs...).Set(float64(group.UpSpeed))
+	}
+	m.tagSeries.deleteStale(im.TagTorrents, im.TagSize, im.TagDlSpeed, im.TagUpSpeed)
+
+	im.Uncategorized.WithLabelValues().Set(float64(summary.Uncategorized))
+	im.Untagged.WithLabelValues().Set(float64(summary.Untagged))
+}
+
+func (m *Metrics) UpdateTaskBackoff(task string, backoff time.Duration) {
+	sm := m.scheduler
+	sm.TaskBackoff.WithLabelValues(task).Set(backoff.Seconds())
+}
+
+func (m *Metrics) IncTaskError(task, reason string) {
+	sm := m.scheduler
+	sm.TaskErrors.WithLabelValues(task, reason).Inc()
+}
+
+// disableTorrentMetrics drops optional torrent metrics
+// turned off in config, nil metrics are neither registered nor set
+func (m *Metrics) disableTorrentMetrics() {
+	tm, on := m.torrent, torrentOptional
+	toggles := []struct {
+		enabled bool
+		metric  **gaugeVec
+	}{
+		{on.Size, &tm.Size},
+		{on.TotalSize, &tm.TotalSize},
+		{on.UploadedSession, &tm.UploadedSession},
+		{on.DownloadedSession, &tm.DownloadedSession},
+		{on.Availability, &tm.Availability},
+		{on.SeedingTime, &tm.SeedingTime},
+		{on.TimeActive, &tm.TimeActive},
+		{on.AddedOn, &tm.AddedOn},
+		{on.CompletionOn, &tm.CompletionOn},
+		{on.LastActivity, &tm.LastActivity},
+		{on.NumComplete, &tm.NumComplete},
+		{on.NumIncomplete, &tm.NumIncomplete},
+		{on.DlLimit, &tm.DlLimit},
+		{on.UpLimit, &tm.UpLimit},
+		{on.Priority, &tm.Priority},
+		{on.MaxRatio, &tm.MaxRatio},
+		{on.SeedingTimeLimit, &tm.SeedingTimeLimit},
+	}
+	for _, toggle := range toggles {
+		if !toggle.enabled {
+			*toggle.metric = nil
+		}
+	}
+}
+
+// minutesToSeconds converts qBittorrent limits set in minutes,
+// negative values are special and kept as is
+func minutesToSeconds(minutes int64) float64 {
+	if minutes <= 0 {
+		return float64(minutes)
+	}
+	return float64(minutes * 60)
+}
+
+// deleteTorrent deletes series of a removed or renamed torrent
+// from every vector with the name or hash label
+func (m *Metrics) deleteTorrent(labels prometheus.Labels) {
+	for _, metrics := range []any{m.torrent, m.problems, m.goals, m.rolling} {
+		val := reflect.ValueOf(metrics).Elem()
+		for i := range val.NumField() {
+			if vec, ok := val.Field(i).Interface().(*gaugeVec); ok {
+				vec.DeletePartialMatch(labels)
+			}
+		}
+	}
+}
+
+// labelSets tracks label values set by an update, so series
+// missing from the next one are deleted instead of resetting
+// the vectors, which scrapes in between would see empty
+type labelSets struct {
+	last    map[string][]string
+	current map[string][]string
+}
+
+func newLabelSets() *labelSets {
+	return &labelSets{last: map[string][]string{}, current: map[string][]string{}}
+}
+
+// add records label values of the current update and returns them
+func (s *labelSets) add(lvs ...string) []string {
+	s.current[strings.Join(lvs, "\xff")] = lvs
+	return lvs
+}
+
+// deleteStale deletes label sets of the last update
+// missing from the current one and completes the update
+func (s *labelSets) deleteStale(vecs ...*gaugeVec) {
+	for key, lvs := range s.last {
+		if _, ok := s.current[key]; ok {
+			continue
+		}
+		for _, vec := range vecs {
+			vec.DeleteLabelValues(lvs...)
+		}
+	}
+	s.last, s.current = s.current, map[string][]string{}
+}
+
 // registerMetrics accepts MetricsStruct
 // which contains multiple metrics fields
 func registerMetrics(metrics any) {
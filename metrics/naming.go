@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	NamingLegacy = "legacy"
+	NamingV2     = "v2"
+	NamingBoth   = "both"
+)
+
+var naming = NamingLegacy
+
+// metricOpts describes a metric exported under v2 Name
+// and, depending on the naming mode, under LegacyName
+type metricOpts struct {
+	Name       string
+	LegacyName string
+	Help       string
+}
+
+// descriptor is an entry of the metrics reference
+type descriptor struct {
+	Name       string
+	LegacyName string
+	Type       string
+	Help       string
+	Labels     []string
+}
+
+// catalogue holds descriptors of all metrics
+// created by the package, regardless of naming
+var catalogue []descriptor
+
+func UpdateNaming(mode string) error {
+	switch mode {
+	case NamingLegacy, NamingV2, NamingBoth:
+		naming = mode
+		return nil
+	default:
+		return fmt.Errorf("invalid metrics naming: %s, expected %s, %s or %s", mode, NamingLegacy, NamingV2, NamingBoth)
+	}
+}
+
+// exportedNames returns prefixed names
+// exported in the current naming mode
+func (o metricOpts) exportedNames() []string {
+	switch {
+	case o.LegacyName == "":
+		return []string{metricsPrefix + o.Name}
+	case o.Name == "" && naming == NamingV2:
+		// legacy only metric without v2 replacement
+		return nil
+	case o.Name == "":
+		return []string{metricsPrefix + o.LegacyName}
+	case naming == NamingBoth && o.Name != o.LegacyName:
+		return []string{metricsPrefix + o.Name, metricsPrefix + o.LegacyName}
+	case naming == NamingV2, naming == NamingBoth:
+		return []string{metricsPrefix + o.Name}
+	default:
+		return []string{metricsPrefix + o.LegacyName}
+	}
+}
+
+func (o metricOpts) record(typ string, labels []string) {
+	d := descriptor{Type: typ, Help: o.Help, Labels: labels}
+	if o.Name != "" {
+		d.Name = metricsPrefix + o.Name
+	}
+	if o.LegacyName != "" {
+		d.LegacyName = metricsPrefix + o.LegacyName
+	}
+	catalogue = append(catalogue, d)
+}
+
+// gaugeVec sets the same values to gauges
+// of every name exported in the naming mode.
+// nil gaugeVec is a disabled metric, setting it is a no-op
+type gaugeVec struct {
+	vecs   []*prometheus.GaugeVec
+	labels []string
+}
+
+type gauges []prometheus.Gauge
+
+func newGaugeVec(o metricOpts, labels []string) *gaugeVec {
+	o.record("gauge", labels)
+	g := &gaugeVec{labels: labels}
+	for _, name := range o.exportedNames() {
+		g.vecs = append(g.vecs, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name,
+			Help: o.Help,
+		}, labels))
+	}
+	return g
+}
+
+func (g *gaugeVec) WithLabelValues(lvs ...string) gauges {
+	if g == nil {
+		return nil
+	}
+	gs := make(gauges, 0, len(g.vecs))
+	for _, vec := range g.vecs {
+		gs = append(gs, vec.WithLabelValues(lvs...))
+	}
+	return gs
+}
+
+func (g *gaugeVec) DeleteLabelValues(lvs ...string) {
+	if g == nil {
+		return
+	}
+	for _, vec := range g.vecs {
+		vec.DeleteLabelValues(lvs...)
+	}
+}
+
+// DeletePartialMatch deletes series matching the labels,
+// vectors missing any of the labels are left intact
+func (g *gaugeVec) DeletePartialMatch(labels prometheus.Labels) {
+	if g == nil {
+		return
+	}
+	for label := range labels {
+		if !slices.Contains(g.labels, label) {
+			return
+		}
+	}
+	for _, vec := range g.vecs {
+		vec.DeletePartialMatch(labels)
+	}
+}
+
+func (g *gaugeVec) Describe(ch chan<- *prometheus.Desc) {
+	for _, vec := range g.vecs {
+		vec.Describe(ch)
+	}
+}
+
+func (g *gaugeVec) Collect(ch chan<- prometheus.Metric) {
+	for _, vec := range g.vecs {
+		vec.Collect(ch)
+	}
+}
+
+func (gs gauges) Set(value float64) {
+	for _, g := range gs {
+		g.Set(value)
+	}
+}
+
+func newCounterVec(o metricOpts, labels []string) *prometheus.CounterVec {
+	o.record("counter", labels)
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + o.Name,
+		Help: o.Help,
+	}, labels)
+}
+
+// newDesc creates a descriptor for const metrics of custom collectors
+func newDesc(o metricOpts, typ string, labels []string) *prometheus.Desc {
+	o.record(typ, labels)
+	return prometheus.NewDesc(metricsPrefix+o.Name, o.Help, labels, nil)
+}
+
+// Reference renders all metrics as a markdown table
+func Reference() string {
+	Get()
+	entries := slices.Clone(catalogue)
+	slices.SortStableFunc(entries, func(a, b descriptor) int {
+		return strings.Compare(a.sortKey(), b.sortKey())
+	})
+
+	var sb strings.Builder
+	sb.WriteString("| Name | Legacy name | Type | Labels | Description |\n")
+	sb.WriteString("| ---- | ----------- | ---- | ------ | ----------- |\n")
+	for _, d := range entries {
+		labels := make([]string, len(d.Labels))
+		for i, label := range d.Labels {
+			labels[i] = "`" + label + "`"
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n",
+			code(d.Name), code(d.LegacyName), d.Type, strings.Join(labels, ", "), d.Help)
+	}
+	return sb.String()
+}
+
+func (d descriptor) sortKey() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.LegacyName
+}
+
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
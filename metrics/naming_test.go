@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"os"
+	"qbittorrent_exporter/lib/log"
+	"strings"
+	"testing"
+)
+
+// TestReferenceUpToDate fails when docs/Metrics.md doesn't match the metrics,
+// regenerate it with `qbittorrent_exporter -log-level error -metrics-reference`
+func TestReferenceUpToDate(t *testing.T) {
+	log.Set("error", "default")
+	doc, err := os.ReadFile("../docs/Metrics.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := strings.Index(string(doc), "| Name | Legacy name |")
+	end := strings.Index(string(doc), "<!-- end of generated reference -->")
+	if start < 0 || end < start {
+		t.Fatal("generated reference not found in docs/Metrics.md")
+	}
+	if got, want := string(doc[start:end]), Reference(); got != want {
+		t.Errorf("docs/Metrics.md is out of date, regenerate it with `qbittorrent_exporter -log-level error -metrics-reference`\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
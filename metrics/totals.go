@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"qbittorrent_exporter/state"
+	"qbittorrent_exporter/types"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// totalsCollector exports monotonic byte totals as counters.
+// Values come from the state and the last torrents poll,
+// so they are emitted as const metrics on every scrape
+type totalsCollector struct {
+	transferDownloaded *prometheus.Desc
+	transferUploaded   *prometheus.Desc
+	torrentDownloaded  *prometheus.Desc
+	// torrentUploaded is nil when disabled in config
+	torrentUploaded *prometheus.Desc
+
+	mu sync.Mutex
+	// transfer is nil until the first transfer poll
+	transfer *state.TransferInfoState
+	// torrents are keyed by hash, names may repeat
+	torrents map[string]torrentTotals
+}
+
+type torrentTotals struct {
+	name       string
+	downloaded int64
+	uploaded   int64
+}
+
+func newTotalsCollector(uploaded bool) *totalsCollector {
+	c := &totalsCollector{
+		transferDownloaded: newDesc(metricOpts{
+			Name: "transfer_downloaded_bytes_total",
+			Help: "Data downloaded over all sessions recorded by the exporter",
+		}, "counter", nil),
+		transferUploaded: newDesc(metricOpts{
+			Name: "transfer_uploaded_bytes_total",
+			Help: "Data uploaded over all sessions recorded by the exporter",
+		}, "counter", nil),
+		torrentDownloaded: newDesc(metricOpts{
+			Name: "torrent_downloaded_bytes_total",
+			Help: "Data downloaded by the torrent",
+		}, "counter", []string{"hash", "name"}),
+		torrents: map[string]torrentTotals{},
+	}
+	torrentUploaded := newDesc(metricOpts{
+		Name: "torrent_uploaded_bytes_total",
+		Help: "Data uploaded by the torrent",
+	}, "counter", []string{"hash", "name"})
+	if uploaded {
+		c.torrentUploaded = torrentUploaded
+	}
+	return c
+}
+
+func (c *totalsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.transferDownloaded
+	ch <- c.transferUploaded
+	ch <- c.torrentDownloaded
+	if c.torrentUploaded != nil {
+		ch <- c.torrentUploaded
+	}
+}
+
+func (c *totalsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.transfer != nil {
+		ch <- prometheus.MustNewConstMetric(c.transferDownloaded, prometheus.CounterValue, float64(c.transfer.DlInfoDataTotal))
+		ch <- prometheus.MustNewConstMetric(c.transferUploaded, prometheus.CounterValue, float64(c.transfer.UpInfoDataTotal))
+	}
+	for hash, totals := range c.torrents {
+		ch <- prometheus.MustNewConstMetric(c.torrentDownloaded, prometheus.CounterValue, float64(totals.downloaded), hash, totals.name)
+		if c.torrentUploaded != nil {
+			ch <- prometheus.MustNewConstMetric(c.torrentUploaded, prometheus.CounterValue, float64(totals.uploaded), hash, totals.name)
+		}
+	}
+}
+
+func (c *totalsCollector) updateTransfer(ti state.TransferInfoState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transfer = &ti
+}
+
+// updateTorrents replaces the previous poll,
+// so removed torrents stop being exported
+func (c *totalsCollector) updateTorrents(torrents []types.Torrent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.torrents = make(map[string]torrentTotals, len(torrents))
+	for _, torrent := range torrents {
+		c.torrents[torrent.Hash] = torrentTotals{
+			name:       torrent.Name,
+			downloaded: torrent.Downloaded,
+			uploaded:   torrent.Uploaded,
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package problems
+
+import (
+	"qbittorrent_exporter/config"
+	"qbittorrent_exporter/state"
+	"qbittorrent_exporter/types"
+	"sync"
+	"time"
+)
+
+// Reasons of detected problems
+const (
+	ReasonError        = "error"
+	ReasonMissingFiles = "missing_files"
+	ReasonStalled      = "stalled"
+	ReasonSlow         = "slow"
+	ReasonUnavailable  = "unavailable"
+	ReasonNoPeers      = "no_peers"
+)
+
+// Reasons lists every reason a torrent can be reported with
+var Reasons = []string{
+	ReasonError,
+	ReasonMissingFiles,
+	ReasonStalled,
+	ReasonSlow,
+	ReasonUnavailable,
+	ReasonNoPeers,
+}
+
+type Problem struct {
+	Hash   string
+	Name   string
+	Reason string
+}
+
+// Detector evaluates rules over successive torrents snapshots.
+// Conditions which must hold for a while are tracked in memory
+// since the first snapshot they were observed in
+type Detector struct {
+	cfg config.ProblemsConfig
+
+	mu sync.Mutex
+	// since is keyed by torrent hash and reason
+	since map[problemKey]time.Time
+}
+
+type problemKey struct {
+	hash   string
+	reason string
+}
+
+func NewDetector(cfg config.ProblemsConfig) *Detector {
+	return &Detector{
+		cfg:   cfg,
+		since: map[problemKey]time.Time{},
+	}
+}
+
+// Evaluate returns problems of the torrents, states are
+// records of the state package used for state durations
+func (d *Detector) Evaluate(torrents []types.Torrent, states map[string]state.TorrentState, now time.Time) []Problem {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var problems []Problem
+	since := make(map[problemKey]time.Time, len(d.since))
+	report := func(torrent types.Torrent, reason string) {
+		problems = append(problems, Problem{Hash: torrent.Hash, Name: torrent.Name, Reason: reason})
+	}
+	// lasting reports the reason when the condition
+	// has held for at least the duration
+	lasting := func(torrent types.Torrent, reason string, duration time.Duration) {
+		key := problemKey{torrent.Hash, reason}
+		start, ok := d.since[key]
+		if !ok {
+			start = now
+		}
+		since[key] = start
+		if now.Sub(start) >= duration {
+			report(torrent, reason)
+		}
+	}
+
+	for _, torrent := range torrents {
+		switch torrent.State {
+		case types.StateError:
+			if d.cfg.Errored {
+				report(torrent, ReasonError)
+			}
+		case types.StateMissingFiles:
+			if d.cfg.Errored {
+				report(torrent, ReasonMissingFiles)
+			}
+		}
+
+		if d.cfg.StalledFor > 0 && torrent.State == types.StateStalledDL {
+			if st, ok := states[torrent.Hash]; ok && now.Sub(time.Unix(st.Since, 0)) >= d.cfg.StalledFor {
+				report(torrent, ReasonStalled)
+			}
+		}
+
+		if d.cfg.SlowSpeed > 0 && isDownloading(torrent.State) && torrent.Dlspeed < int64(d.cfg.SlowSpeed) {
+			lasting(torrent, ReasonSlow, d.cfg.SlowFor)
+		}
+
+		// availability is negative when qBittorrent doesn't know it
+		if d.cfg.Unavailable && isDownloading(torrent.State) && torrent.Availability >= 0 && torrent.Availability < 1 {
+			report(torrent, ReasonUnavailable)
+		}
+
+		if d.cfg.NoPeersFor > 0 && types.IsSeeding(torrent.State) && torrent.NumSeeds+torrent.NumLeechs == 0 {
+			lasting(torrent, ReasonNoPeers, d.cfg.NoPeersFor)
+		}
+	}
+	// conditions which no longer hold start over
+	d.since = since
+
+	return problems
+}
+
+func isDownloading(state string) bool {
+	switch state {
+	case types.StateDownloading, types.StateForcedDL, types.StateStalledDL, types.StateMetaDL, types.StateForcedMetaDL:
+		return true
+	}
+	return false
+}
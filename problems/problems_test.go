@@ -0,0 +1,116 @@
+package problems
+
+import (
+	"qbittorrent_exporter/config"
+	"qbittorrent_exporter/state"
+	"qbittorrent_exporter/types"
+	"slices"
+	"testing"
+	"time"
+)
+
+var epoch = time.Unix(1700000000, 0)
+
+func TestDetectorLasting(t *testing.T) {
+	cfg := config.ProblemsConfig{
+		SlowSpeed:  1000,
+		SlowFor:    30 * time.Minute,
+		NoPeersFor: time.Hour,
+	}
+	slow := types.Torrent{Hash: "abc", Name: "ubuntu.iso", State: types.StateDownloading, Dlspeed: 10}
+	fast := slow
+	fast.Dlspeed = 5000
+	lonely := types.Torrent{Hash: "abc", Name: "ubuntu.iso", State: types.StateStalledUP}
+	peered := lonely
+	peered.NumLeechs = 1
+
+	type snapshot struct {
+		after   time.Duration
+		torrent types.Torrent
+	}
+	tests := []struct {
+		name      string
+		snapshots []snapshot
+		want      []string
+	}{
+		{"slow just seen", []snapshot{{0, slow}}, nil},
+		{"slow not long enough", []snapshot{{0, slow}, {29 * time.Minute, slow}}, nil},
+		{"slow long enough", []snapshot{{0, slow}, {30 * time.Minute, slow}}, []string{ReasonSlow}},
+		{"slow starts over after speeding up", []snapshot{{0, slow}, {20 * time.Minute, fast}, {40 * time.Minute, slow}}, nil},
+		{"slow counted from the restart", []snapshot{{0, slow}, {20 * time.Minute, fast}, {40 * time.Minute, slow}, {70 * time.Minute, slow}}, []string{ReasonSlow}},
+		{"no peers long enough", []snapshot{{0, lonely}, {time.Hour, lonely}}, []string{ReasonNoPeers}},
+		{"no peers interrupted", []snapshot{{0, lonely}, {30 * time.Minute, peered}, {time.Hour, lonely}}, nil},
+		{"removed torrent starts over", []snapshot{{0, lonely}, {30 * time.Minute, types.Torrent{}}, {time.Hour, lonely}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector(cfg)
+			var found []Problem
+			for _, s := range tt.snapshots {
+				var torrents []types.Torrent
+				if s.torrent.Hash != "" {
+					torrents = append(torrents, s.torrent)
+				}
+				found = d.Evaluate(torrents, nil, epoch.Add(s.after))
+			}
+			if got := reasons(found); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectorStalled(t *testing.T) {
+	stalled := types.Torrent{Hash: "abc", Name: "ubuntu.iso", State: types.StateStalledDL, Availability: -1}
+	tests := []struct {
+		name   string
+		states map[string]state.TorrentState
+		want   []string
+	}{
+		{"no state record", nil, nil},
+		{"stalled recently", map[string]state.TorrentState{"abc": {State: types.StateStalledDL, Since: epoch.Add(-time.Hour).Unix()}}, nil},
+		{"stalled long enough", map[string]state.TorrentState{"abc": {State: types.StateStalledDL, Since: epoch.Add(-2 * time.Hour).Unix()}}, []string{ReasonStalled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector(config.ProblemsConfig{StalledFor: 2 * time.Hour})
+			got := reasons(d.Evaluate([]types.Torrent{stalled}, tt.states, epoch))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectorImmediate(t *testing.T) {
+	cfg := config.ProblemsConfig{Errored: true, Unavailable: true}
+	tests := []struct {
+		name    string
+		torrent types.Torrent
+		want    []string
+	}{
+		{"error", types.Torrent{State: types.StateError}, []string{ReasonError}},
+		{"missing files", types.Torrent{State: types.StateMissingFiles}, []string{ReasonMissingFiles}},
+		{"unavailable", types.Torrent{State: types.StateDownloading, Availability: 0.5}, []string{ReasonUnavailable}},
+		{"available", types.Torrent{State: types.StateDownloading, Availability: 1}, nil},
+		{"unknown availability", types.Torrent{State: types.StateDownloading, Availability: -1}, nil},
+		{"seeding with low availability", types.Torrent{State: types.StateUploading, Availability: 0.5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.torrent.Hash = "abc"
+			got := reasons(NewDetector(cfg).Evaluate([]types.Torrent{tt.torrent}, nil, epoch))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func reasons(problems []Problem) []string {
+	var reasons []string
+	for _, p := range problems {
+		reasons = append(reasons, p.Reason)
+	}
+	return reasons
+}
@@ -0,0 +1,164 @@
+package rolling
+
+import (
+	"qbittorrent_exporter/types"
+	"strings"
+	"sync"
+	"time"
+)
+
+// samplesPerWindow bounds memory used by every window of a torrent,
+// samples are kept at least window/samplesPerWindow apart
+const samplesPerWindow = 60
+
+// Rates are transfers of a torrent within a window
+type Rates struct {
+	Hash   string
+	Name   string
+	Window time.Duration
+	// Coverage is the part of the window covered by samples,
+	// rates are zero until it's positive
+	Coverage   time.Duration
+	Downloaded int64
+	Uploaded   int64
+	// rates in bytes/s over the covered part of the window
+	DownloadRate float64
+	UploadRate   float64
+}
+
+type sample struct {
+	at         time.Time
+	downloaded int64
+	uploaded   int64
+}
+
+// ring holds samples of a window, oldest first
+type ring struct {
+	window  time.Duration
+	samples []sample
+	start   int
+}
+
+func newRing(window time.Duration) *ring {
+	return &ring{
+		window:  window,
+		samples: make([]sample, 0, samplesPerWindow+1),
+	}
+}
+
+func (r *ring) len() int {
+	return len(r.samples)
+}
+
+func (r *ring) at(i int) sample {
+	return r.samples[(r.start+i)%len(r.samples)]
+}
+
+func (r *ring) last() sample {
+	return r.at(r.len() - 1)
+}
+
+func (r *ring) push(s sample) {
+	if r.len() > 0 && s.at.Sub(r.last().at) < r.window/samplesPerWindow {
+		return
+	}
+	if r.len() < cap(r.samples) {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.start] = s
+	r.start = (r.start + 1) % len(r.samples)
+}
+
+// oldest returns the oldest sample within the window
+func (r *ring) oldest(now time.Time) (sample, bool) {
+	for i := range r.len() {
+		if s := r.at(i); now.Sub(s.at) <= r.window {
+			return s, true
+		}
+	}
+	return sample{}, false
+}
+
+func (r *ring) reset() {
+	r.samples = r.samples[:0]
+	r.start = 0
+}
+
+// Stats keeps rolling windows of transferred data per torrent
+// computed from deltas of downloaded and uploaded totals
+type Stats struct {
+	windows []time.Duration
+
+	mu sync.Mutex
+	// rings are keyed by torrent hash
+	rings map[string][]*ring
+}
+
+func NewStats(windows []time.Duration) *Stats {
+	return &Stats{
+		windows: windows,
+		rings:   map[string][]*ring{},
+	}
+}
+
+// Update records the torrents snapshot and returns rates
+// of every window, removed torrents are forgotten
+func (s *Stats) Update(torrents []types.Torrent, now time.Time) []Rates {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rates []Rates
+	rings := make(map[string][]*ring, len(torrents))
+	for _, torrent := range torrents {
+		current := sample{at: now, downloaded: torrent.Downloaded, uploaded: torrent.Uploaded}
+		torrentRings, ok := s.rings[torrent.Hash]
+		if !ok {
+			torrentRings = make([]*ring, len(s.windows))
+			for i, window := range s.windows {
+				torrentRings[i] = newRing(window)
+			}
+		}
+		rings[torrent.Hash] = torrentRings
+
+		for _, r := range torrentRings {
+			// totals go down when a torrent is re-added or rechecked
+			if r.len() > 0 && (current.downloaded < r.last().downloaded || current.uploaded < r.last().uploaded) {
+				r.reset()
+			}
+			r.push(current)
+			rates = append(rates, r.rates(torrent, current))
+		}
+	}
+	s.rings = rings
+
+	return rates
+}
+
+func (r *ring) rates(torrent types.Torrent, current sample) Rates {
+	rates := Rates{Hash: torrent.Hash, Name: torrent.Name, Window: r.window}
+	oldest, ok := r.oldest(current.at)
+	if !ok {
+		return rates
+	}
+	rates.Downloaded = current.downloaded - oldest.downloaded
+	rates.Uploaded = current.uploaded - oldest.uploaded
+	rates.Coverage = current.at.Sub(oldest.at)
+	if elapsed := rates.Coverage.Seconds(); elapsed > 0 {
+		rates.DownloadRate = float64(rates.Downloaded) / elapsed
+		rates.UploadRate = float64(rates.Uploaded) / elapsed
+	}
+	return rates
+}
+
+// FormatWindow formats the window without zero units, e.g. 5m or 1h30m
+func FormatWindow(window time.Duration) string {
+	s := window.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
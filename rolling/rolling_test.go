@@ -0,0 +1,226 @@
+package rolling
+
+import (
+	"qbittorrent_exporter/types"
+	"testing"
+	"time"
+)
+
+var epoch = time.Unix(1700000000, 0)
+
+func at(seconds int) time.Time {
+	return epoch.Add(time.Duration(seconds) * time.Second)
+}
+
+func TestRingPush(t *testing.T) {
+	tests := []struct {
+		name string
+		// pushes are seconds since epoch, window is 1m
+		// so samples are kept at least 1s apart
+		pushes     []int
+		wantLen    int
+		wantOldest int
+	}{
+		{"empty", nil, 0, 0},
+		{"single", []int{0}, 1, 0},
+		{"too close is skipped", []int{0, 0, 1}, 2, 0},
+		{"spaced", []int{0, 1, 2, 3}, 4, 0},
+		{"full", seq(0, 61), samplesPerWindow + 1, 0},
+		{"wraps around", seq(0, 70), samplesPerWindow + 1, 9},
+		{"wraps twice", seq(0, 200), samplesPerWindow + 1, 139},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRing(time.Minute)
+			for _, s := range tt.pushes {
+				r.push(sample{at: at(s)})
+			}
+			if r.len() != tt.wantLen {
+				t.Fatalf("len() = %d, want %d", r.len(), tt.wantLen)
+			}
+			if r.len() == 0 {
+				return
+			}
+			if got := r.at(0).at; !got.Equal(at(tt.wantOldest)) {
+				t.Errorf("at(0) = %v, want %v", got.Sub(epoch), time.Duration(tt.wantOldest)*time.Second)
+			}
+			if got := r.last().at; !got.Equal(at(tt.pushes[len(tt.pushes)-1])) {
+				t.Errorf("last() = %v, want the last push", got.Sub(epoch))
+			}
+		})
+	}
+}
+
+func TestRingOldest(t *testing.T) {
+	tests := []struct {
+		name     string
+		pushes   []int
+		now      int
+		want     int
+		wantNone bool
+	}{
+		{"empty", nil, 0, 0, true},
+		{"all within window", []int{0, 10, 20}, 30, 0, false},
+		{"boundary is within window", []int{0, 30, 60}, 60, 0, false},
+		{"older samples skipped", []int{0, 30, 61}, 90, 30, false},
+		{"all outside window", []int{0, 10}, 200, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRing(time.Minute)
+			for _, s := range tt.pushes {
+				r.push(sample{at: at(s)})
+			}
+			got, ok := r.oldest(at(tt.now))
+			if ok == tt.wantNone {
+				t.Fatalf("oldest() ok = %v, want %v", ok, !tt.wantNone)
+			}
+			if ok && !got.at.Equal(at(tt.want)) {
+				t.Errorf("oldest() = %v, want %v", got.at.Sub(epoch), time.Duration(tt.want)*time.Second)
+			}
+		})
+	}
+}
+
+func TestRingReset(t *testing.T) {
+	r := newRing(time.Minute)
+	for _, s := range seq(0, 70) {
+		r.push(sample{at: at(s)})
+	}
+	r.reset()
+	if r.len() != 0 {
+		t.Fatalf("len() = %d after reset, want 0", r.len())
+	}
+	r.push(sample{at: at(100)})
+	r.push(sample{at: at(101)})
+	if r.len() != 2 || !r.at(0).at.Equal(at(100)) || !r.last().at.Equal(at(101)) {
+		t.Errorf("ring after reset holds %d samples from %v to %v", r.len(), r.at(0).at.Sub(epoch), r.last().at.Sub(epoch))
+	}
+}
+
+func TestStatsUpdate(t *testing.T) {
+	type poll struct {
+		at         int
+		downloaded int64
+	}
+	tests := []struct {
+		name         string
+		polls        []poll
+		wantCoverage time.Duration
+		wantBytes    int64
+		wantRate     float64
+	}{
+		{
+			name:  "first poll covers nothing",
+			polls: []poll{{0, 1000}},
+		},
+		{
+			name:         "partially covered window",
+			polls:        []poll{{0, 0}, {10, 1000}, {20, 2000}},
+			wantCoverage: 20 * time.Second,
+			wantBytes:    2000,
+			wantRate:     100,
+		},
+		{
+			name:         "older polls leave the window",
+			polls:        []poll{{0, 0}, {30, 3000}, {60, 6000}, {90, 12000}},
+			wantCoverage: 60 * time.Second,
+			wantBytes:    9000,
+			wantRate:     150,
+		},
+		{
+			name:         "interval grows within the window",
+			polls:        []poll{{0, 0}, {10, 1000}, {20, 2000}, {50, 5000}},
+			wantCoverage: 50 * time.Second,
+			wantBytes:    5000,
+			wantRate:     100,
+		},
+		{
+			name:         "interval shrinks below sample spacing",
+			polls:        []poll{{0, 0}, {30, 3000}, {30, 3050}},
+			wantCoverage: 30 * time.Second,
+			wantBytes:    3050,
+			wantRate:     3050.0 / 30,
+		},
+		{
+			name:  "interval longer than the window",
+			polls: []poll{{0, 0}, {10, 1000}, {130, 13000}},
+		},
+		{
+			name:         "recovers after the interval shrinks back",
+			polls:        []poll{{0, 0}, {130, 13000}, {140, 14000}},
+			wantCoverage: 10 * time.Second,
+			wantBytes:    1000,
+			wantRate:     100,
+		},
+		{
+			name:  "total going down starts over",
+			polls: []poll{{0, 5000}, {10, 6000}, {20, 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := NewStats([]time.Duration{time.Minute})
+			var rates []Rates
+			for _, p := range tt.polls {
+				torrents := []types.Torrent{{Hash: "abc", Name: "ubuntu.iso", Downloaded: p.downloaded}}
+				rates = stats.Update(torrents, at(p.at))
+			}
+			if len(rates) != 1 {
+				t.Fatalf("got %d rates, want 1", len(rates))
+			}
+			got := rates[0]
+			if got.Coverage != tt.wantCoverage || got.Downloaded != tt.wantBytes || got.DownloadRate != tt.wantRate {
+				t.Errorf("got coverage %v, downloaded %d, rate %v; want %v, %d, %v",
+					got.Coverage, got.Downloaded, got.DownloadRate, tt.wantCoverage, tt.wantBytes, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestStatsForgetsRemovedTorrents(t *testing.T) {
+	stats := NewStats([]time.Duration{time.Minute, time.Hour})
+	torrent := types.Torrent{Hash: "abc", Name: "ubuntu.iso"}
+	stats.Update([]types.Torrent{torrent}, at(0))
+	if rates := stats.Update(nil, at(10)); len(rates) != 0 {
+		t.Fatalf("got %d rates without torrents, want 0", len(rates))
+	}
+	torrent.Downloaded = 1000
+	rates := stats.Update([]types.Torrent{torrent}, at(20))
+	if len(rates) != 2 {
+		t.Fatalf("got %d rates, want one per window", len(rates))
+	}
+	for _, r := range rates {
+		if r.Coverage != 0 {
+			t.Errorf("window %v of a re-added torrent covers %v, want 0", r.Window, r.Coverage)
+		}
+	}
+}
+
+func TestFormatWindow(t *testing.T) {
+	tests := []struct {
+		window time.Duration
+		want   string
+	}{
+		{30 * time.Second, "30s"},
+		{5 * time.Minute, "5m"},
+		{90 * time.Minute, "1h30m"},
+		{time.Hour, "1h"},
+		{24 * time.Hour, "24h"},
+		{time.Minute + 30*time.Second, "1m30s"},
+	}
+	for _, tt := range tests {
+		if got := FormatWindow(tt.window); got != tt.want {
+			t.Errorf("FormatWindow(%v) = %q, want %q", tt.window, got, tt.want)
+		}
+	}
+}
+
+// seq returns seconds from start to end, end excluded
+func seq(start, end int) []int {
+	s := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		s = append(s, i)
+	}
+	return s
+}
@@ -1,12 +1,15 @@
 package state
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"qbittorrent_exporter/lib/log"
 	"qbittorrent_exporter/lib/scheduler"
+	"qbittorrent_exporter/types"
 	"qbittorrent_exporter/validator"
 	"sync"
 	"time"
@@ -21,6 +24,8 @@ var (
 
 type State struct {
 	TransferInfo TransferInfoState `json:"transfer_info"`
+	// Torrents are keyed by torrent hash
+	Torrents map[string]TorrentState `json:"torrents,omitempty"`
 }
 
 type TransferInfoState struct {
@@ -30,20 +35,27 @@ type TransferInfoState struct {
 	UpInfoDataTotal int64 `json:"up_info_data_total"`
 }
 
-func init() {
-	scheduler.Run(func() error {
-		lock.Lock()
-		defer lock.Unlock()
-		if transientMode || singleInstance == nil {
-			return nil
-		}
-		return singleInstance.write()
+// RunPeriodicWrite schedules persisting of the state
+// into the state file every interval
+func RunPeriodicWrite(interval time.Duration) {
+	scheduler.Run("state", func(ctx context.Context) error {
+		return Flush()
 	}, &scheduler.PeriodicTaskOpts{
-		Interval: 30 * time.Second,
+		Interval: interval,
 		IsFast:   false,
 	})
 }
 
+// Flush writes the state into the state file immediately
+func Flush() error {
+	lock.Lock()
+	defer lock.Unlock()
+	if transientMode || singleInstance == nil {
+		return nil
+	}
+	return singleInstance.write()
+}
+
 func UpdatePath(path string) {
 	lock.Lock()
 	defer lock.Unlock()
@@ -102,6 +114,31 @@ func readState(path string) *State {
 	return &state
 }
 
+// TorrentState records when the torrent entered its current state
+type TorrentState struct {
+	State string `json:"state"`
+	Since int64  `json:"since"`
+}
+
+// UpdateTorrentStates records state changes of the polled torrents
+// and forgets removed ones, it returns a copy of the records
+func (s *State) UpdateTorrentStates(torrents []types.Torrent, now time.Time) map[string]TorrentState {
+	lock.Lock()
+	defer lock.Unlock()
+
+	records := make(map[string]TorrentState, len(torrents))
+	for _, torrent := range torrents {
+		record, ok := s.Torrents[torrent.Hash]
+		if !ok || record.State != torrent.State {
+			record = TorrentState{State: torrent.State, Since: now.Unix()}
+		}
+		records[torrent.Hash] = record
+	}
+	s.Torrents = records
+
+	return maps.Clone(records)
+}
+
 func (s *State) UpdateTransferInfo(dl, up int64) {
 	lock.Lock()
 	defer lock.Unlock()
@@ -0,0 +1,81 @@
+package types
+
+import "slices"
+
+// Torrent states reported by qBittorrent,
+// names follow qBittorrent 5.x
+const (
+	StateError              = "error"
+	StateMissingFiles       = "missingFiles"
+	StateUploading          = "uploading"
+	StateStoppedUP          = "stoppedUP"
+	StateQueuedUP           = "queuedUP"
+	StateStalledUP          = "stalledUP"
+	StateCheckingUP         = "checkingUP"
+	StateForcedUP           = "forcedUP"
+	StateAllocating         = "allocating"
+	StateDownloading        = "downloading"
+	StateMetaDL             = "metaDL"
+	StateForcedMetaDL       = "forcedMetaDL"
+	StateStoppedDL          = "stoppedDL"
+	StateQueuedDL           = "queuedDL"
+	StateStalledDL          = "stalledDL"
+	StateCheckingDL         = "checkingDL"
+	StateForcedDL           = "forcedDL"
+	StateCheckingResumeData = "checkingResumeData"
+	StateMoving             = "moving"
+	StateUnknown            = "unknown"
+)
+
+// States lists every known state
+var States = []string{
+	StateError,
+	StateMissingFiles,
+	StateUploading,
+	StateStoppedUP,
+	StateQueuedUP,
+	StateStalledUP,
+	StateCheckingUP,
+	StateForcedUP,
+	StateAllocating,
+	StateDownloading,
+	StateMetaDL,
+	StateForcedMetaDL,
+	StateStoppedDL,
+	StateQueuedDL,
+	StateStalledDL,
+	StateCheckingDL,
+	StateForcedDL,
+	StateCheckingResumeData,
+	StateMoving,
+	StateUnknown,
+}
+
+// legacyStates maps names used before qBittorrent 5.0
+var legacyStates = map[string]string{
+	"pausedUP": StateStoppedUP,
+	"pausedDL": StateStoppedDL,
+}
+
+// NormalizeState returns the state name used by
+// qBittorrent 5.x for states renamed across versions,
+// states missing from States are reported as StateUnknown
+func NormalizeState(state string) string {
+	if normalized, ok := legacyStates[state]; ok {
+		return normalized
+	}
+	if !slices.Contains(States, state) {
+		return StateUnknown
+	}
+	return state
+}
+
+// IsSeeding reports states in which a complete torrent
+// is uploaded and qBittorrent counts seeding time
+func IsSeeding(state string) bool {
+	switch state {
+	case StateUploading, StateForcedUP, StateStalledUP:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package types
+
+import "testing"
+
+func TestNormalizeState(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"pausedUP", StateStoppedUP},
+		{"pausedDL", StateStoppedDL},
+		{StateStoppedUP, StateStoppedUP},
+		{StateDownloading, StateDownloading},
+		{StateUnknown, StateUnknown},
+		{"somethingNew", StateUnknown},
+		{"", StateUnknown},
+		{"Downloading", StateUnknown},
+	}
+	for _, tt := range tests {
+		if got := NormalizeState(tt.in); got != tt.want {
+			t.Errorf("NormalizeState(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsSeeding(t *testing.T) {
+	seeding := map[string]bool{StateUploading: true, StateForcedUP: true, StateStalledUP: true}
+	for _, state := range States {
+		if got := IsSeeding(state); got != seeding[state] {
+			t.Errorf("IsSeeding(%q) = %v, want %v", state, got, seeding[state])
+		}
+	}
+}
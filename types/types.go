@@ -62,3 +62,31 @@ type Transfer struct {
 	DhtNodes         int64  `json:"dht_nodes"`
 	ConnectionStatus string `json:"connection_status"`
 }
+
+// MainData is a subset of /sync/maindata response
+type MainData struct {
+	Rid         int64       `json:"rid"`
+	FullUpdate  bool        `json:"full_update"`
+	ServerState ServerState `json:"server_state"`
+}
+
+// ServerState fields are nil when they
+// didn't change since the previous sync
+type ServerState struct {
+	FreeSpaceOnDisk *int64 `json:"free_space_on_disk"`
+}
+
+type Category struct {
+	Name     string `json:"name"`
+	SavePath string `json:"savePath"`
+}
+
+// Preferences is a subset of qBittorrent preferences,
+// limits are in bytes/s and 0 means unlimited
+type Preferences struct {
+	DlLimit          int64 `json:"dl_limit"`
+	UpLimit          int64 `json:"up_limit"`
+	AltDlLimit       int64 `json:"alt_dl_limit"`
+	AltUpLimit       int64 `json:"alt_up_limit"`
+	SchedulerEnabled bool  `json:"scheduler_enabled"`
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"qbittorrent_exporter/lib/log"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicAuthHandler checks credentials against users
+// from the web config, re-read on every request
+type basicAuthHandler struct {
+	path string
+	next http.Handler
+
+	mu sync.Mutex
+	// cache of successful bcrypt comparisons,
+	// keyed by hash of user, bcrypt hash and password
+	cache map[[sha256.Size]byte]bool
+}
+
+func newBasicAuthHandler(path string, next http.Handler) *basicAuthHandler {
+	return &basicAuthHandler{
+		path:  path,
+		next:  next,
+		cache: map[[sha256.Size]byte]bool{},
+	}
+}
+
+func (h *basicAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wc, err := loadWebConfig(h.path)
+	if err != nil {
+		log.Error("Unable to load web config: " + err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if len(wc.BasicAuthUsers) == 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	user, password, ok := r.BasicAuth()
+	if ok && h.verify(wc.BasicAuthUsers, user, password) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="qbittorrent_exporter"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func (h *basicAuthHandler) verify(users map[string]string, user, password string) bool {
+	hash, ok := users[user]
+	if !ok {
+		// keep timing similar for unknown users
+		_ = bcrypt.CompareHashAndPassword([]byte("$2y$10$QOauhQNbBCuQDKes6eFzPeMqBSjb7Mr5DUmpZ/VcEd00UAV/LDeSi"), []byte(password))
+		return false
+	}
+
+	key := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	h.mu.Lock()
+	cached := h.cache[key]
+	h.mu.Unlock()
+	if cached {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	h.mu.Lock()
+	h.cache[key] = true
+	h.mu.Unlock()
+	return true
+}
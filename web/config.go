@@ -0,0 +1,109 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"qbittorrent_exporter/lib/parser"
+	"qbittorrent_exporter/lib/tlsconfig"
+	"qbittorrent_exporter/validator"
+)
+
+var configPath string
+
+// WebConfig follows web.config.yml format
+// of the Prometheus exporter-toolkit
+type WebConfig struct {
+	TLSServerConfig TLSServerConfig   `yaml:"tls_server_config"`
+	BasicAuthUsers  map[string]string `yaml:"basic_auth_users"`
+}
+
+type TLSServerConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientAuthType string `yaml:"client_auth_type"`
+	ClientCAFile   string `yaml:"client_ca_file"`
+	MinVersion     string `yaml:"min_version"`
+	MaxVersion     string `yaml:"max_version"`
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+func UpdateConfigPath(path string) {
+	configPath = path
+}
+
+func loadWebConfig(path string) (*WebConfig, error) {
+	var wc WebConfig
+	if err := validator.ValidatePath(path, false); err != nil {
+		return nil, err
+	}
+	if err := parser.ParseYamlFile(path, &wc); err != nil {
+		return nil, fmt.Errorf("error parsing web config: %w", err)
+	}
+	return &wc, nil
+}
+
+func (c *TLSServerConfig) isEnabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// build reads certificates from disk and
+// returns TLS config for a single connection
+func (c *TLSServerConfig) build() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("both cert_file and key_file must be set")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.MinVersion != "" {
+		v, err := tlsconfig.ParseVersion(c.MinVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_version: %w", err)
+		}
+		cfg.MinVersion = v
+	}
+	if c.MaxVersion != "" {
+		v, err := tlsconfig.ParseVersion(c.MaxVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_version: %w", err)
+		}
+		cfg.MaxVersion = v
+	}
+
+	clientAuth, ok := clientAuthTypes[c.ClientAuthType]
+	if !ok {
+		return nil, fmt.Errorf("unknown client_auth_type: %s", c.ClientAuthType)
+	}
+	cfg.ClientAuth = clientAuth
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", c.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+	} else if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("client_ca_file is required for client_auth_type %s", c.ClientAuthType)
+	}
+	return cfg, nil
+}
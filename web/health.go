@@ -0,0 +1,132 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"qbittorrent_exporter/lib/scheduler"
+	"time"
+)
+
+type HealthOpts struct {
+	// OverdueThreshold is how long a periodic task may
+	// miss its next run before the scheduler is unhealthy
+	OverdueThreshold time.Duration
+}
+
+type ReadyOpts struct {
+	// Tasks which must have succeeded recently
+	Tasks []string
+	// StaleIntervals is the number of task intervals after
+	// the last success before the task is considered stale
+	StaleIntervals int
+	LoggedIn       func() bool
+}
+
+type check struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type healthResponse struct {
+	Status string  `json:"status"`
+	Checks []check `json:"checks"`
+}
+
+var startedAt = time.Now()
+
+// HealthHandler reports whether the process
+// is alive and the scheduler keeps running tasks
+func HealthHandler(o HealthOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks := []check{{
+			Name:    "process",
+			OK:      true,
+			Message: "uptime " + time.Since(startedAt).Truncate(time.Second).String(),
+		}}
+		checks = append(checks, schedulerCheck(scheduler.Get().Tasks(), o.OverdueThreshold))
+		writeChecks(w, checks)
+	})
+}
+
+// ReadyHandler reports whether the exporter is logged in
+// to qBittorrent and serves recent data
+func ReadyHandler(o ReadyOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var checks []check
+		if o.LoggedIn != nil {
+			c := check{Name: "login", OK: o.LoggedIn()}
+			if !c.OK {
+				c.Message = "not logged in to qBittorrent"
+			}
+			checks = append(checks, c)
+		}
+		s := scheduler.Get()
+		for _, name := range o.Tasks {
+			ts, ok := s.Task(name)
+			if !ok {
+				checks = append(checks, check{Name: "task:" + name, Message: "task is not registered"})
+				continue
+			}
+			checks = append(checks, taskFreshnessCheck(ts, o.StaleIntervals))
+		}
+		writeChecks(w, checks)
+	})
+}
+
+func schedulerCheck(tasks []scheduler.TaskStatus, overdueThreshold time.Duration) check {
+	c := check{Name: "scheduler", OK: true}
+	periodic := 0
+	for _, ts := range tasks {
+		if !ts.Periodic {
+			continue
+		}
+		periodic++
+		if ts.Running || ts.NextRun.IsZero() {
+			continue
+		}
+		if overdue := time.Since(ts.NextRun); overdue > overdueThreshold {
+			c.OK = false
+			c.Message = fmt.Sprintf("task %s is overdue by %v", ts.Name, overdue.Truncate(time.Second))
+			return c
+		}
+	}
+	if periodic == 0 {
+		c.OK = false
+		c.Message = "no periodic tasks are running"
+	}
+	return c
+}
+
+func taskFreshnessCheck(ts scheduler.TaskStatus, staleIntervals int) check {
+	c := check{Name: "task:" + ts.Name}
+	if ts.LastSuccess.IsZero() {
+		c.Message = "no successful run yet"
+		if ts.LastError != "" {
+			c.Message += ": " + ts.LastError
+		}
+		return c
+	}
+
+	age := time.Since(ts.LastSuccess)
+	maxAge := time.Duration(staleIntervals) * ts.Interval
+	if staleIntervals > 0 && age > maxAge {
+		c.Message = fmt.Sprintf("last success %v ago exceeds %v", age.Truncate(time.Second), maxAge)
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+func writeChecks(w http.ResponseWriter, checks []check) {
+	resp := healthResponse{Status: "ok", Checks: checks}
+	status := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = "fail"
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"qbittorrent_exporter/config"
+	"qbittorrent_exporter/feature"
+	"qbittorrent_exporter/lib/log"
+	"qbittorrent_exporter/lib/scheduler"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+type LandingOpts struct {
+	Version  string
+	Config   config.Config
+	LoggedIn func() bool
+	// ConnectionStatus returns the last connection
+	// status reported by qBittorrent
+	ConnectionStatus func() string
+}
+
+type link struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+}
+
+type instanceView struct {
+	BaseURL            string `json:"base_url"`
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	LoggedIn           bool   `json:"logged_in"`
+	ConnectionStatus   string `json:"connection_status,omitempty"`
+}
+
+type featureView struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+type landingView struct {
+	Version   string         `json:"version"`
+	Instances []instanceView `json:"instances"`
+	Tasks     []taskView     `json:"tasks"`
+	Features  []featureView  `json:"features"`
+	Toggles   []featureView  `json:"toggles"`
+	Links     []link         `json:"links"`
+}
+
+var landingTemplate = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>qBittorrent Exporter</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.fail { color: #b00; }
+</style>
+</head>
+<body>
+<h1>qBittorrent Exporter</h1>
+<p>Version: {{.Version}}</p>
+<ul>
+{{range .Links}}<li><a href="{{.Path}}">{{.Path}}</a> - {{.Description}}</li>
+{{end}}</ul>
+<h2>qBittorrent</h2>
+<table>
+<tr><th>URL</th><th>Username</th><th>Password</th><th>Logged in</th><th>Connection status</th></tr>
+{{range .Instances}}<tr><td>{{.BaseURL}}</td><td>{{.Username}}</td><td>{{.Password}}</td><td>{{.LoggedIn}}</td><td>{{.ConnectionStatus}}</td></tr>
+{{end}}</table>
+<h2>Tasks</h2>
+<table>
+<tr><th>Name</th><th>Interval</th><th>Runs</th><th>Last run</th><th>Duration</th><th>Failures</th><th>Last error</th><th>Next run</th></tr>
+{{range .Tasks}}<tr><td>{{.Name}}</td><td>{{.Interval}}</td><td>{{.Runs}}</td><td>{{with .LastRun}}{{.Format "2006-01-02 15:04:05"}}{{end}}</td><td>{{.LastDuration}}</td><td>{{.ConsecutiveFailures}}</td><td class="fail">{{.LastError}}</td><td>{{with .NextRun}}{{.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+{{end}}</table>
+<h2>Feature flags</h2>
+<table>
+<tr><th>Name</th><th>Enabled</th></tr>
+{{range .Features}}<tr><td>{{.Name}}</td><td>{{.Enabled}}</td></tr>
+{{end}}</table>
+<h2>Config toggles</h2>
+<table>
+<tr><th>Name</th><th>Enabled</th></tr>
+{{range .Toggles}}<tr><td>{{.Name}}</td><td>{{.Enabled}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// LandingHandler serves an overview of the exporter as HTML,
+// or as JSON when requested with ?format=json or Accept header
+func LandingHandler(o LandingOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		view := newLandingView(o)
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			writeJSON(w, http.StatusOK, view)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := landingTemplate.Execute(w, view); err != nil {
+			log.Error("Failed to render landing page: " + err.Error())
+		}
+	})
+}
+
+func newLandingView(o LandingOpts) landingView {
+	qb := o.Config.QBittorrent
+	instance := instanceView{
+		BaseURL:            redactURL(qb.BaseURL),
+		InsecureSkipVerify: qb.InsecureSkipVerify,
+	}
+	if qb.Username != "" {
+		instance.Username = redacted
+	}
+	if qb.Password != "" {
+		instance.Password = redacted
+	}
+	if o.LoggedIn != nil {
+		instance.LoggedIn = o.LoggedIn()
+	}
+	if o.ConnectionStatus != nil {
+		instance.ConnectionStatus = o.ConnectionStatus()
+	}
+
+	tasks := scheduler.Get().Tasks()
+	taskViews := make([]taskView, 0, len(tasks))
+	for _, ts := range tasks {
+		taskViews = append(taskViews, newTaskView(ts))
+	}
+
+	var features []featureView
+	for name, enabled := range feature.All() {
+		features = append(features, featureView{Name: name, Enabled: enabled})
+	}
+	slices.SortFunc(features, func(a, b featureView) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return landingView{
+		Version:   o.Version,
+		Instances: []instanceView{instance},
+		Tasks:     taskViews,
+		Features:  features,
+		Toggles:   newToggleViews(o.Config),
+		Links: []link{
+			{Path: o.Config.Metrics.UrlPath, Description: "Prometheus metrics"},
+			{Path: "/healthz", Description: "Liveness probe"},
+			{Path: "/readyz", Description: "Readiness probe"},
+			{Path: "/debug/tasks", Description: "Scheduler tasks"},
+			{Path: "/?format=json", Description: "This page as JSON"},
+		},
+	}
+}
+
+// newToggleViews lists config options turning optional metrics
+// on and off, named by their path in the config file
+func newToggleViews(cfg config.Config) []featureView {
+	toggles := []featureView{
+		{Name: "problems.enabled", Enabled: cfg.Problems.Enabled},
+		{Name: "goals.enabled", Enabled: cfg.Goals.Enabled},
+		{Name: "rolling.enabled", Enabled: cfg.Rolling.Enabled},
+		{Name: "forecast.enabled", Enabled: cfg.Forecast.Enabled},
+	}
+	torrent := reflect.ValueOf(cfg.Metrics.Torrent)
+	for i := range torrent.NumField() {
+		toggles = append(toggles, featureView{
+			Name:    "metrics.torrent." + torrent.Type().Field(i).Tag.Get("yaml"),
+			Enabled: torrent.Field(i).Bool(),
+		})
+	}
+	return toggles
+}
+
+// redactURL hides user info and query
+// values which may contain credentials
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+const unixPrefix = "unix:"
+
+// Listen opens a TCP listener for host:port addresses
+// or a unix socket for addresses prefixed with "unix:"
+func Listen(address string, socketMode os.FileMode) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixPrefix)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if socketMode != 0 {
+		if err := os.Chmod(path, socketMode); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("set socket permissions: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// ListenerURL returns URL of urlPath served on the listener
+func ListenerURL(l net.Listener, urlPath string) string {
+	scheme := "http"
+	if IsTLSEnabled() {
+		scheme = "https"
+	}
+	addr := l.Addr()
+	if addr.Network() == "unix" {
+		return fmt.Sprintf("%s://unix:%s:%s", scheme, addr.String(), urlPath)
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, addr.String(), urlPath)
+}
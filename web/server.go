@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"qbittorrent_exporter/lib/log"
+	"time"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// Serve serves HTTP or HTTPS on the listener depending on the web config
+// until ctx is done. Certificates and users are re-read from the web
+// config on every connection, so rotated files are picked up
+func Serve(ctx context.Context, server *http.Server, l net.Listener) error {
+	// requests are cancelled together with ctx
+	server.BaseContext = func(net.Listener) context.Context {
+		return ctx
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error("Failed to shut down metrics server: " + err.Error())
+		}
+	}()
+
+	err := serve(server, l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func serve(server *http.Server, l net.Listener) error {
+	if configPath == "" {
+		return server.Serve(l)
+	}
+
+	wc, err := loadWebConfig(configPath)
+	if err != nil {
+		return err
+	}
+	server.Handler = newBasicAuthHandler(configPath, server.Handler)
+	if !wc.TLSServerConfig.isEnabled() {
+		return server.Serve(l)
+	}
+	if _, err := wc.TLSServerConfig.build(); err != nil {
+		return err
+	}
+
+	server.TLSConfig = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			wc, err := loadWebConfig(configPath)
+			if err != nil {
+				log.Error("Unable to load web config: " + err.Error())
+				return nil, err
+			}
+			cfg, err := wc.TLSServerConfig.build()
+			if err != nil {
+				log.Error("Unable to build TLS config: " + err.Error())
+			}
+			return cfg, err
+		},
+	}
+	return server.ServeTLS(l, "", "")
+}
+
+// IsTLSEnabled reports whether the web config enables TLS
+func IsTLSEnabled() bool {
+	if configPath == "" {
+		return false
+	}
+	wc, err := loadWebConfig(configPath)
+	return err == nil && wc.TLSServerConfig.isEnabled()
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"qbittorrent_exporter/lib/log"
+	"qbittorrent_exporter/lib/scheduler"
+	"time"
+)
+
+type taskView struct {
+	Name                string     `json:"name"`
+	Periodic            bool       `json:"periodic"`
+	Interval            string     `json:"interval,omitempty"`
+	Running             bool       `json:"running"`
+	Runs                int        `json:"runs"`
+	LastRun             *time.Time `json:"last_run,omitempty"`
+	LastDuration        string     `json:"last_duration,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Backoff             string     `json:"backoff,omitempty"`
+	NextRun             *time.Time `json:"next_run,omitempty"`
+}
+
+// TasksHandler serves status of scheduler tasks as JSON
+func TasksHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tasks := scheduler.Get().Tasks()
+		views := make([]taskView, 0, len(tasks))
+		for _, ts := range tasks {
+			views = append(views, newTaskView(ts))
+		}
+		writeJSON(w, http.StatusOK, views)
+	})
+}
+
+func newTaskView(ts scheduler.TaskStatus) taskView {
+	view := taskView{
+		Name:                ts.Name,
+		Periodic:            ts.Periodic,
+		Running:             ts.Running,
+		Runs:                ts.Runs,
+		LastRun:             optionalTime(ts.LastRun),
+		LastSuccess:         optionalTime(ts.LastSuccess),
+		LastError:           ts.LastError,
+		ConsecutiveFailures: ts.ConsecutiveFailures,
+		NextRun:             optionalTime(ts.NextRun),
+	}
+	if ts.Periodic {
+		view.Interval = ts.Interval.String()
+		view.Backoff = ts.Backoff.String()
+	}
+	if ts.Runs > 0 {
+		view.LastDuration = ts.LastDuration.String()
+	}
+	return view
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		log.Error("Failed to encode JSON response: " + err.Error())
+	}
+}